@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/imroc/req/v3"
+)
+
+const (
+	titleHistoryCacheTTL = time.Hour
+)
+
+type cacheEntry struct {
+	Fetched time.Time       `json:"fetched"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// cacheGet decodes the entry stored under key into v, reporting whether it
+// was found and is younger than ttl.
+func cacheGet(kv *bolt.DB, bucket, key string, ttl time.Duration, v any) (found bool, err error) {
+	err = kv.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		raw := b.Get([]byte(key))
+		if raw == nil {
+			return nil
+		}
+
+		entry := cacheEntry{}
+		if err := json.Unmarshal(raw, &entry); err != nil {
+			return err
+		}
+
+		if timeNow().Sub(entry.Fetched) > ttl {
+			return nil
+		}
+
+		found = true
+		return json.Unmarshal(entry.Data, v)
+	})
+
+	return found, err
+}
+
+func cachePut(kv *bolt.DB, bucket, key string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	raw, err := json.Marshal(cacheEntry{Fetched: timeNow(), Data: data})
+	if err != nil {
+		return err
+	}
+
+	return kv.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		return b.Put([]byte(key), raw)
+	})
+}
+
+func cacheDelete(kv *bolt.DB, bucket, key string) error {
+	return kv.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(bucket)).Delete([]byte(key))
+	})
+}
+
+func cachedTitleHistory(client *req.Client, kv *bolt.DB, xuid string) (*XBLTitleHistory, error) {
+	result := &XBLTitleHistory{}
+
+	found, err := cacheGet(kv, "xboxlive_titlehistory", xuid, titleHistoryCacheTTL, result)
+	if err != nil || found {
+		return result, err
+	}
+
+	result, err = xblGetTitleHistory(client, xuid)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, cachePut(kv, "xboxlive_titlehistory", xuid, result)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/imroc/req/v3"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func openTestKV(t *testing.T) *bolt.DB {
+	kv, err := bolt.Open(filepath.Join(t.TempDir(), "kv.db"), 0600, nil)
+	if err != nil {
+		t.Fatalf("failed to open test kv: %s", err)
+	}
+	t.Cleanup(func() { kv.Close() })
+
+	err = createBuckets(kv)
+	if err != nil {
+		t.Fatalf("failed to create buckets: %s", err)
+	}
+
+	return kv
+}
+
+func TestCachedTitleHistory(t *testing.T) {
+	cases := map[string]struct {
+		age   time.Duration
+		calls int
+	}{
+		"fresh entry": {
+			age:   time.Minute,
+			calls: 1,
+		},
+		"expired entry": {
+			age:   titleHistoryCacheTTL + time.Minute,
+			calls: 2,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			kv := openTestKV(t)
+			xblthjson := openTestFile(t, "XBLTitleHistory", "recent_titles.json")
+
+			client := req.C()
+			httpmock.ActivateNonDefault(client.GetClient())
+			httpmock.ZeroCallCounters()
+			httpmock.RegisterResponder("GET", "https://xbl.io/api/v2/player/titleHistory/test", func(request *http.Request) (*http.Response, error) {
+				resp := httpmock.NewBytesResponse(http.StatusOK, xblthjson)
+				return resp, nil
+			})
+
+			now := time.Date(2024, 2, 4, 0, 0, 0, 0, time.UTC)
+			setTimeNow(t, now)
+
+			first, err := cachedTitleHistory(client, kv, "test")
+			assert.Nil(t, err)
+
+			setTimeNow(t, now.Add(tc.age))
+
+			second, err := cachedTitleHistory(client, kv, "test")
+			assert.Nil(t, err)
+
+			assert.Equal(t, first.Titles[0].Name, second.Titles[0].Name)
+			assert.Equal(t, len(first.Titles), len(second.Titles))
+			assert.Equal(t, tc.calls, httpmock.GetTotalCallCount())
+		})
+	}
+}
+
+func TestCachedTitleHistoryErrorStatus(t *testing.T) {
+	kv := openTestKV(t)
+	xblthjson := openTestFile(t, "XBLTitleHistory", "recent_titles.json")
+
+	statuses := []int{http.StatusTooManyRequests, http.StatusOK}
+
+	client := req.C()
+	httpmock.ActivateNonDefault(client.GetClient())
+	httpmock.ZeroCallCounters()
+	httpmock.RegisterResponder("GET", "https://xbl.io/api/v2/player/titleHistory/limited", func(request *http.Request) (*http.Response, error) {
+		status := statuses[0]
+		statuses = statuses[1:]
+		return httpmock.NewBytesResponse(status, xblthjson), nil
+	})
+
+	setTimeNow(t, time.Date(2024, 2, 4, 0, 0, 0, 0, time.UTC))
+
+	_, err := cachedTitleHistory(client, kv, "limited")
+	assert.ErrorIs(t, err, errorStatusErr)
+
+	history, err := cachedTitleHistory(client, kv, "limited")
+	assert.Nil(t, err)
+	assert.NotEmpty(t, history.Titles)
+	assert.Equal(t, 2, httpmock.GetTotalCallCount())
+}
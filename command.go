@@ -0,0 +1,288 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gowon-irc/go-gowon"
+)
+
+var (
+	unterminatedQuoteErr = errors.New("unterminated quote")
+	unknownFlagErr       = errors.New("unknown flag")
+	missingFlagValueErr  = errors.New("flag needs a value")
+)
+
+// commandPrefix is what messages to the module start with, used when showing
+// how to run a command.
+var commandPrefix = "."
+
+// word is one argument of a command line. Quoted words are never flags.
+type word struct {
+	text   string
+	quoted bool
+}
+
+// splitCommandLine splits msg into words on whitespace. Double quotes group
+// words, so gamertags and game names with spaces can be passed as one
+// argument, and a backslash escapes the next character.
+func splitCommandLine(msg string) ([]word, error) {
+	words := []word{}
+
+	var b strings.Builder
+	inWord, inQuote, quoted, escaped := false, false, false, false
+
+	for _, c := range msg {
+		switch {
+		case escaped:
+			b.WriteRune(c)
+			escaped = false
+		case c == '\\':
+			inWord, escaped = true, true
+		case c == '"':
+			inWord, quoted = true, true
+			inQuote = !inQuote
+		case !inQuote && (c == ' ' || c == '\t'):
+			if inWord {
+				words = append(words, word{b.String(), quoted})
+				b.Reset()
+			}
+			inWord, quoted = false, false
+		default:
+			b.WriteRune(c)
+			inWord = true
+		}
+	}
+
+	if inQuote {
+		return nil, unterminatedQuoteErr
+	}
+
+	if inWord {
+		words = append(words, word{b.String(), quoted})
+	}
+
+	return words, nil
+}
+
+// flagError is a flag passed to a command that it can't use.
+type flagError struct {
+	Flag string
+	Err  error
+}
+
+func (e *flagError) Error() string {
+	return fmt.Sprintf("%s: --%s", e.Err, e.Flag)
+}
+
+func (e *flagError) Unwrap() error {
+	return e.Err
+}
+
+// commandFlag is a --flag a command accepts. Flags with a Value take one,
+// as either --name=value or --name value, the rest are switches.
+type commandFlag struct {
+	Name  string
+	Value string
+}
+
+// command is one subcommand of .xbl.
+type command struct {
+	Name    string
+	Aliases []string
+	// Args describes the positional arguments, such as "<user> <game>".
+	Args string
+	// MinArgs is how many positional arguments must be passed.
+	MinArgs int
+	Flags   []commandFlag
+	// Examples are arguments shown in the help for the command.
+	Examples []string
+	Run      func(c *commandCall) (string, error)
+}
+
+// commandCall is a command being run, with its arguments parsed.
+type commandCall struct {
+	Command *command
+	Msg     gowon.Message
+	Reply   *reply
+	// Name is the name or alias the command was called by.
+	Name  string
+	Args  []string
+	Flags map[string]string
+}
+
+// arg returns the i'th positional argument, or nothing if there aren't that
+// many.
+func (c *commandCall) arg(i int) string {
+	if i >= len(c.Args) {
+		return ""
+	}
+
+	return c.Args[i]
+}
+
+// rest joins the positional arguments from i on, so unquoted game names can
+// still be passed.
+func (c *commandCall) rest(i int) string {
+	if i >= len(c.Args) {
+		return ""
+	}
+
+	return strings.Join(c.Args[i:], " ")
+}
+
+// flag returns the value of a flag and whether it was passed. Switches have
+// no value.
+func (c *commandCall) flag(name string) (string, bool) {
+	v, ok := c.Flags[name]
+	return v, ok
+}
+
+// usageError replies with how the command should have been run.
+func (c *commandCall) usageError() string {
+	return c.Reply.render("error.usage", map[string]any{"Usage": c.Command.usage()})
+}
+
+func (cmd *command) flag(name string) (commandFlag, bool) {
+	for _, f := range cmd.Flags {
+		if f.Name == name {
+			return f, true
+		}
+	}
+
+	return commandFlag{}, false
+}
+
+// usage shows how to run the command, such as
+// ".xbl game <user> <game> [--flag <value>]".
+func (cmd *command) usage() string {
+	parts := []string{fmt.Sprintf("%sxbl %s", commandPrefix, cmd.Name)}
+
+	if cmd.Args != "" {
+		parts = append(parts, cmd.Args)
+	}
+
+	for _, f := range cmd.Flags {
+		if f.Value == "" {
+			parts = append(parts, fmt.Sprintf("[--%s]", f.Name))
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("[--%s <%s>]", f.Name, f.Value))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// parse separates the flags in words from the positional arguments. A bare
+// -- ends the flags, so anything after it is positional.
+func (cmd *command) parse(words []word) (args []string, flags map[string]string, err error) {
+	args = []string{}
+	flags = map[string]string{}
+	flagsDone := false
+
+	for i := 0; i < len(words); i++ {
+		w := words[i]
+
+		if flagsDone || w.quoted || !strings.HasPrefix(w.text, "--") {
+			args = append(args, w.text)
+			continue
+		}
+
+		if w.text == "--" {
+			flagsDone = true
+			continue
+		}
+
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(w.text, "--"), "=")
+		name = strings.ToLower(name)
+
+		f, ok := cmd.flag(name)
+		if !ok {
+			return nil, nil, &flagError{name, unknownFlagErr}
+		}
+
+		if f.Value == "" {
+			if hasValue {
+				return nil, nil, &flagError{name, unknownFlagErr}
+			}
+			flags[name] = ""
+			continue
+		}
+
+		if !hasValue {
+			if i+1 >= len(words) {
+				return nil, nil, &flagError{name, missingFlagValueErr}
+			}
+			i++
+			value = words[i].text
+		}
+
+		flags[name] = value
+	}
+
+	return args, flags, nil
+}
+
+// commands is the registry of .xbl subcommands, in the order they're listed
+// to users.
+type commands []*command
+
+// find looks up a command by name or alias.
+func (cs commands) find(name string) *command {
+	name = strings.ToLower(name)
+
+	for _, cmd := range cs {
+		if cmd.Name == name {
+			return cmd
+		}
+
+		for _, alias := range cmd.Aliases {
+			if alias == name {
+				return cmd
+			}
+		}
+	}
+
+	return nil
+}
+
+// run parses the arguments of m and runs the command they name. Mistakes in
+// the arguments are replied to with the command's usage.
+func (cs commands) run(m gowon.Message, r *reply) (string, error) {
+	words, err := splitCommandLine(m.Args)
+	if err != nil {
+		return r.render("error.unterminated_quote", nil), nil
+	}
+
+	if len(words) == 0 {
+		return cs.usage(r), nil
+	}
+
+	cmd := cs.find(words[0].text)
+	if cmd == nil {
+		return cs.usage(r), nil
+	}
+
+	args, flags, err := cmd.parse(words[1:])
+	var fe *flagError
+	if errors.As(err, &fe) {
+		return r.render("error.bad_flag", map[string]any{"Flag": fe.Flag, "Usage": cmd.usage()}), nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if len(args) < cmd.MinArgs {
+		return r.render("error.usage", map[string]any{"Usage": cmd.usage()}), nil
+	}
+
+	return cmd.Run(&commandCall{
+		Command: cmd,
+		Msg:     m,
+		Reply:   r,
+		Name:    strings.ToLower(words[0].text),
+		Args:    args,
+		Flags:   flags,
+	})
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gowon-irc/go-gowon"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitCommandLine(t *testing.T) {
+	cases := map[string]struct {
+		in       string
+		expected []word
+		err      error
+	}{
+		"empty": {
+			in:       "  ",
+			expected: []word{},
+		},
+		"plain words": {
+			in:       "game  dave halo",
+			expected: []word{{"game", false}, {"dave", false}, {"halo", false}},
+		},
+		"quoted words": {
+			in:       `game "Major Nelson" "Halo Infinite"`,
+			expected: []word{{"game", false}, {"Major Nelson", true}, {"Halo Infinite", true}},
+		},
+		"quote inside a word": {
+			in:       `set Major" "Nelson`,
+			expected: []word{{"set", false}, {"Major Nelson", true}},
+		},
+		"empty quotes": {
+			in:       `r ""`,
+			expected: []word{{"r", false}, {"", true}},
+		},
+		"escaped quote": {
+			in:       `w \"quoted\" game`,
+			expected: []word{{"w", false}, {`"quoted"`, false}, {"game", false}},
+		},
+		"apostrophe": {
+			in:       "w assassin's creed",
+			expected: []word{{"w", false}, {"assassin's", false}, {"creed", false}},
+		},
+		"unterminated": {
+			in:  `game "Major Nelson`,
+			err: unterminatedQuoteErr,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			out, err := splitCommandLine(tc.in)
+			assert.Equal(t, tc.expected, out)
+			assert.ErrorIs(t, err, tc.err)
+		})
+	}
+}
+
+func TestCommandParse(t *testing.T) {
+	cmd := &command{
+		Name:  "recent",
+		Flags: []commandFlag{{Name: "window", Value: "window"}, {Name: "locked"}},
+	}
+
+	cases := map[string]struct {
+		in    string
+		args  []string
+		flags map[string]string
+		err   error
+	}{
+		"no flags": {
+			in:    "dave 7d",
+			args:  []string{"dave", "7d"},
+			flags: map[string]string{},
+		},
+		"value after equals": {
+			in:    "dave --window=7d",
+			args:  []string{"dave"},
+			flags: map[string]string{"window": "7d"},
+		},
+		"value as next word": {
+			in:    "--window 7d dave",
+			args:  []string{"dave"},
+			flags: map[string]string{"window": "7d"},
+		},
+		"switch": {
+			in:    "--LOCKED dave",
+			args:  []string{"dave"},
+			flags: map[string]string{"locked": ""},
+		},
+		"quoted flag is an argument": {
+			in:    `"--locked"`,
+			args:  []string{"--locked"},
+			flags: map[string]string{},
+		},
+		"double dash ends flags": {
+			in:    "-- --locked",
+			args:  []string{"--locked"},
+			flags: map[string]string{},
+		},
+		"unknown flag": {
+			in:  "--order score",
+			err: unknownFlagErr,
+		},
+		"switch with a value": {
+			in:  "--locked=yes",
+			err: unknownFlagErr,
+		},
+		"missing value": {
+			in:  "dave --window",
+			err: missingFlagValueErr,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			words, err := splitCommandLine(tc.in)
+			assert.Nil(t, err)
+
+			args, flags, err := cmd.parse(words)
+			assert.Equal(t, tc.args, args)
+			assert.Equal(t, tc.flags, flags)
+			assert.ErrorIs(t, err, tc.err)
+		})
+	}
+}
+
+func TestCommandUsage(t *testing.T) {
+	cases := map[string]struct {
+		cmd      *command
+		expected string
+	}{
+		"no arguments": {
+			cmd:      &command{Name: "online"},
+			expected: ".xbl online",
+		},
+		"arguments": {
+			cmd:      &command{Name: "game", Args: "<user> <game>"},
+			expected: ".xbl game <user> <game>",
+		},
+		"flags": {
+			cmd:      &command{Name: "rare", Args: "[user]", Flags: []commandFlag{{Name: "locked"}, {Name: "window", Value: "window"}}},
+			expected: ".xbl rare [user] [--locked] [--window <window>]",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.cmd.usage())
+		})
+	}
+}
+
+func TestCommandsRun(t *testing.T) {
+	echo := func(c *commandCall) (string, error) {
+		flags := []string{}
+		for _, f := range c.Command.Flags {
+			if v, ok := c.flag(f.Name); ok {
+				flags = append(flags, f.Name+"="+v)
+			}
+		}
+		return c.Name + ": " + strings.Join(c.Args, "|") + " " + strings.Join(flags, ","), nil
+	}
+
+	cmds := commands{
+		{Name: "game", Aliases: []string{"g"}, Args: "<user> <game>", MinArgs: 2, Run: echo},
+		{Name: "rare", Aliases: []string{"chase"}, Flags: []commandFlag{{Name: "locked"}}, Run: echo},
+	}
+
+	cases := map[string]struct {
+		args     string
+		expected string
+	}{
+		"command": {
+			args:     `game "Major Nelson" halo infinite`,
+			expected: "game: Major Nelson|halo|infinite ",
+		},
+		"alias": {
+			args:     "CHASE dave",
+			expected: "chase: dave ",
+		},
+		"flag": {
+			args:     "rare --locked dave",
+			expected: "rare: dave locked=",
+		},
+		"no command": {
+			args:     "",
+			expected: "one of [g]ame or rare must be passed as a command",
+		},
+		"unknown command": {
+			args:     "invalid command",
+			expected: "one of [g]ame or rare must be passed as a command",
+		},
+		"too few arguments": {
+			args:     "g dave",
+			expected: "Error: usage: .xbl game <user> <game>",
+		},
+		"bad flag": {
+			args:     "rare --secret",
+			expected: "Error: can't use --secret, usage: .xbl rare [--locked]",
+		},
+		"unterminated quote": {
+			args:     `game "dave`,
+			expected: "Error: missing closing quote",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			out, err := cmds.run(gowon.Message{Nick: "dave", Dest: "#gowon", Args: tc.args}, testReply())
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, out)
+		})
+	}
+}
+
+func TestXblCommandsUnique(t *testing.T) {
+	seen := map[string]string{}
+
+	for _, cmd := range xblCommands(nil, nil, nil, nil) {
+		for _, name := range append([]string{cmd.Name}, cmd.Aliases...) {
+			other, ok := seen[name]
+			assert.False(t, ok, "%s is used by both %s and %s", name, other, cmd.Name)
+			seen[name] = cmd.Name
+		}
+	}
+}
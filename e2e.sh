@@ -13,6 +13,7 @@ check_command() {
 
 check_command mosquitto_pub
 check_command mosquitto_sub
+check_command jq
 
 get_command() {
     line="${1}"
@@ -48,9 +49,7 @@ mqtt_pub() {
 }
 
 extract_msg() {
-    OUTPUT="${1##*msg\":\"}"
-    OUTPUT="${OUTPUT%%\"*}"
-    echo "${OUTPUT}"
+    jq -r .msg <<< "${1}"
 }
 
 blue() {
@@ -65,12 +64,9 @@ red() {
     echo "[0;31m${@}[0m"
 }
 
-# input message|expected output
-TEST_LINES="$(
-cat << EOF
-.xbl invalid command|one of [s]et, [r]ecent, [a]chievements or [p]layer must be passed as a command
-EOF
-)"
+# input message|expected output, generated from the command registry with
+# go test -run TestE2ELines -update-e2e
+TEST_LINES="$(cat "$(dirname "${0}")/testdata/e2e.txt")"
 
 MSG_COUNT="$(wc -l <<< "${TEST_LINES}")"
 
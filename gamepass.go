@@ -0,0 +1,232 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/imroc/req/v3"
+)
+
+const (
+	gamePassCacheTTL     = time.Hour * 24
+	gamePassListLimit    = 10
+	gamePassProductBatch = 20
+	gamePassMarket       = "US"
+	gamePassLanguage     = "en-us"
+
+	gamePassAllSigl     = "f6f1f99f-9b49-4ccd-b3bf-4d9767a77f5e"
+	gamePassAddedSigl   = "f13cf6b4-57e6-4459-89df-6aec18cf0538"
+	gamePassLeavingSigl = "393f05bf-e596-4ef6-9487-6d4fa0eab987"
+)
+
+// GamePassSigl is a Game Pass catalogue list. The first entry describes the
+// list itself, every following entry holds a product id.
+type GamePassSigl []struct {
+	SiglID string `json:"siglId"`
+	Title  string `json:"title"`
+	ID     string `json:"id"`
+}
+
+func (gps GamePassSigl) ProductIDs() (out []string) {
+	out = []string{}
+
+	for _, e := range gps {
+		if e.ID != "" {
+			out = append(out, e.ID)
+		}
+	}
+
+	return out
+}
+
+type DisplayCatalogProducts struct {
+	Products []struct {
+		ProductID           string `json:"ProductId"`
+		LocalizedProperties []struct {
+			ProductTitle string `json:"ProductTitle"`
+		} `json:"LocalizedProperties"`
+	} `json:"Products"`
+}
+
+type GamePassProduct struct {
+	ProductID string `json:"productId"`
+	Name      string `json:"name"`
+}
+
+type GamePassCatalogue struct {
+	All     []GamePassProduct `json:"all"`
+	Added   []GamePassProduct `json:"added"`
+	Leaving []GamePassProduct `json:"leaving"`
+}
+
+func productNames(products []GamePassProduct) (out []string) {
+	out = []string{}
+
+	for _, p := range products {
+		out = append(out, p.Name)
+	}
+
+	return out
+}
+
+func containsProduct(products []GamePassProduct, id string) bool {
+	for _, p := range products {
+		if p.ProductID == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (gpc *GamePassCatalogue) Match(name string) (out []GamePassProduct) {
+	out = []GamePassProduct{}
+
+	for _, i := range matchNames(productNames(gpc.All), name) {
+		out = append(out, gpc.All[i])
+	}
+
+	return out
+}
+
+func gamePassGetSigl(client *req.Client, id string) ([]string, error) {
+	result := GamePassSigl{}
+
+	resp, err := client.R().
+		SetQueryParams(map[string]string{
+			"id":       id,
+			"language": gamePassLanguage,
+			"market":   gamePassMarket,
+		}).
+		SetSuccessResult(&result).
+		Get("https://catalog.gamepass.com/sigls/v2")
+
+	return result.ProductIDs(), checkResponse(resp, err)
+}
+
+// gamePassGetNames looks up product names in batches, returning a map of
+// product id to name.
+func gamePassGetNames(client *req.Client, ids []string) (map[string]string, error) {
+	names := map[string]string{}
+
+	for i := 0; i < len(ids); i += gamePassProductBatch {
+		batch := ids[i:min(i+gamePassProductBatch, len(ids))]
+		result := &DisplayCatalogProducts{}
+
+		resp, err := client.R().
+			SetQueryParams(map[string]string{
+				"bigIds":    strings.Join(batch, ","),
+				"market":    gamePassMarket,
+				"languages": gamePassLanguage,
+			}).
+			SetSuccessResult(&result).
+			Get("https://displaycatalog.mp.microsoft.com/v7.0/products")
+
+		err = checkResponse(resp, err)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, p := range result.Products {
+			if len(p.LocalizedProperties) != 0 {
+				names[p.ProductID] = p.LocalizedProperties[0].ProductTitle
+			}
+		}
+	}
+
+	return names, nil
+}
+
+func gamePassGetCatalogue(client *req.Client) (*GamePassCatalogue, error) {
+	lists := map[string][]string{}
+	ids := []string{}
+	seen := map[string]bool{}
+
+	for _, sigl := range []string{gamePassAllSigl, gamePassAddedSigl, gamePassLeavingSigl} {
+		l, err := gamePassGetSigl(client, sigl)
+		if err != nil {
+			return nil, err
+		}
+		lists[sigl] = l
+
+		for _, id := range l {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+
+	names, err := gamePassGetNames(client, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	products := func(l []string) (out []GamePassProduct) {
+		out = []GamePassProduct{}
+		for _, id := range l {
+			if n, ok := names[id]; ok {
+				out = append(out, GamePassProduct{ProductID: id, Name: n})
+			}
+		}
+		return out
+	}
+
+	return &GamePassCatalogue{
+		All:     products(lists[gamePassAllSigl]),
+		Added:   products(lists[gamePassAddedSigl]),
+		Leaving: products(lists[gamePassLeavingSigl]),
+	}, nil
+}
+
+func cachedGamePassCatalogue(client *req.Client, kv *bolt.DB) (*GamePassCatalogue, error) {
+	result := &GamePassCatalogue{}
+
+	found, err := cacheGet(kv, "xboxlive_gamepass", "catalogue", gamePassCacheTTL, result)
+	if err != nil || found {
+		return result, err
+	}
+
+	result, err = gamePassGetCatalogue(client)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, cachePut(kv, "xboxlive_gamepass", "catalogue", result)
+}
+
+func xblGamePass(r *reply, catalogue *GamePassCatalogue, title string) string {
+	switch title {
+	case "":
+		return r.render("error.gamepass_needed", nil)
+	case "new":
+		if len(catalogue.Added) == 0 {
+			return r.render("gamepass.new.none", nil)
+		}
+		return r.render("gamepass.new", GamePassListData{Products: catalogue.Added, Limit: gamePassListLimit})
+	case "leaving":
+		if len(catalogue.Leaving) == 0 {
+			return r.render("gamepass.leaving.none", nil)
+		}
+		return r.render("gamepass.leaving", GamePassListData{Products: catalogue.Leaving, Limit: gamePassListLimit})
+	}
+
+	matches := catalogue.Match(title)
+
+	if len(matches) == 0 {
+		return r.render("gamepass.missing", map[string]any{"Title": title})
+	}
+
+	if len(matches) > 1 {
+		return multipleMatchError(r, title, productNames(matches))
+	}
+
+	p := matches[0]
+
+	return r.render("gamepass", GamePassData{
+		Product: p,
+		Added:   containsProduct(catalogue.Added, p.ProductID),
+		Leaving: containsProduct(catalogue.Leaving, p.ProductID),
+	})
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/imroc/req/v3"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func registerGamePassResponders(t *testing.T, client *req.Client) {
+	httpmock.ActivateNonDefault(client.GetClient())
+
+	for sigl, fn := range map[string]string{
+		gamePassAllSigl:     "all.json",
+		gamePassAddedSigl:   "added.json",
+		gamePassLeavingSigl: "leaving.json",
+	} {
+		sigljson := openTestFile(t, "GamePassSigl", fn)
+		httpmock.RegisterResponderWithQuery("GET", "https://catalog.gamepass.com/sigls/v2", map[string]string{
+			"id":       sigl,
+			"language": gamePassLanguage,
+			"market":   gamePassMarket,
+		}, func(request *http.Request) (*http.Response, error) {
+			resp := httpmock.NewBytesResponse(http.StatusOK, sigljson)
+			return resp, nil
+		})
+	}
+
+	productsjson := openTestFile(t, "DisplayCatalogProducts", "products.json")
+	httpmock.RegisterResponder("GET", "https://displaycatalog.mp.microsoft.com/v7.0/products", func(request *http.Request) (*http.Response, error) {
+		resp := httpmock.NewBytesResponse(http.StatusOK, productsjson)
+		return resp, nil
+	})
+}
+
+func TestGamePassGetCatalogue(t *testing.T) {
+	client := req.C()
+	registerGamePassResponders(t, client)
+
+	out, err := gamePassGetCatalogue(client)
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"Halo Infinite", "Forza Horizon 5", "Lies of P", "Halo: The Master Chief Collection", "Persona 3 Reload"}, productNames(out.All))
+	assert.Equal(t, []GamePassProduct{{ProductID: "9PMQDM08SNK9", Name: "Persona 3 Reload"}}, out.Added)
+	assert.Equal(t, []GamePassProduct{{ProductID: "9NKX70BBCDRN", Name: "Lies of P"}}, out.Leaving)
+}
+
+func TestXblGamePass(t *testing.T) {
+	cases := map[string]struct {
+		title    string
+		expected string
+	}{
+		"no title": {
+			title:    "",
+			expected: "Error: game name, new or leaving needed",
+		},
+		"not on game pass": {
+			title:    "halo 3",
+			expected: "halo 3 is not on game pass",
+		},
+		"on game pass": {
+			title:    "forza horizon 5",
+			expected: "{cyan}Forza Horizon 5{clear} is on game pass",
+		},
+		"leaving soon": {
+			title:    "lies of p",
+			expected: "{cyan}Lies of P{clear} is on game pass ({red}leaving soon{clear})",
+		},
+		"recently added": {
+			title:    "persona",
+			expected: "{cyan}Persona 3 Reload{clear} is on game pass ({green}recently added{clear})",
+		},
+		"ambiguous": {
+			title:    "halo",
+			expected: "multiple games match halo: {green}Halo Infinite{clear}, {red}Halo: The Master Chief Collection{clear}",
+		},
+		"new": {
+			title:    "new",
+			expected: "recently added to game pass: {green}Persona 3 Reload{clear}",
+		},
+		"leaving": {
+			title:    "leaving",
+			expected: "leaving game pass soon: {green}Lies of P{clear}",
+		},
+	}
+
+	client := req.C()
+	registerGamePassResponders(t, client)
+
+	catalogue, err := gamePassGetCatalogue(client)
+	assert.Nil(t, err)
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			out := xblGamePass(testReply(), catalogue, tc.title)
+			assert.Equal(t, tc.expected, out)
+		})
+	}
+}
+
+func TestCachedGamePassCatalogueErrorStatus(t *testing.T) {
+	kv := openTestKV(t)
+
+	client := req.C()
+	registerGamePassResponders(t, client)
+	httpmock.RegisterResponder("GET", "https://displaycatalog.mp.microsoft.com/v7.0/products", httpmock.NewStringResponder(http.StatusServiceUnavailable, ""))
+	t.Cleanup(func() { registerGamePassResponders(t, client) })
+
+	_, err := cachedGamePassCatalogue(client, kv)
+	assert.ErrorIs(t, err, errorStatusErr)
+
+	found, err := cacheGet(kv, "xboxlive_gamepass", "catalogue", gamePassCacheTTL, &GamePassCatalogue{})
+	assert.Nil(t, err)
+	assert.False(t, found)
+}
@@ -9,6 +9,7 @@ require (
 	github.com/imroc/req/v3 v3.42.3
 	github.com/jarcoal/httpmock v1.3.1
 	github.com/jessevdk/go-flags v1.5.0
+	github.com/robfig/cron/v3 v3.0.1
 	github.com/stretchr/testify v1.9.0
 )
 
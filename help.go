@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// helpName shows a command's name as in the usage, marking an alias that
+// abbreviates it, such as [r]ecent.
+func helpName(cmd *command) string {
+	for _, alias := range cmd.Aliases {
+		if alias != cmd.Name && strings.HasPrefix(cmd.Name, alias) {
+			return "[" + alias + "]" + strings.TrimPrefix(cmd.Name, alias)
+		}
+	}
+
+	return cmd.Name
+}
+
+// names lists every command as in the usage.
+func (cs commands) names() []string {
+	names := []string{}
+
+	for _, cmd := range cs {
+		names = append(names, helpName(cmd))
+	}
+
+	return names
+}
+
+// examples shows how the examples of cmd are run in full.
+func (cmd *command) examples() []string {
+	examples := []string{}
+
+	for _, e := range cmd.Examples {
+		examples = append(examples, fmt.Sprintf("%sxbl %s %s", commandPrefix, cmd.Name, e))
+	}
+
+	return examples
+}
+
+// helpUsage shows how to run the help command, if there is one.
+func (cs commands) helpUsage() string {
+	cmd := cs.find("help")
+	if cmd == nil {
+		return ""
+	}
+
+	return cmd.usage()
+}
+
+// usage renders the reply to a message without a command.
+func (cs commands) usage(r *reply) string {
+	return r.render("usage", map[string]any{"Commands": cs.names(), "Help": cs.helpUsage()})
+}
+
+// helpHandler lists the commands, or explains the one called name. Each
+// command's description is the help.<name> template.
+func helpHandler(r *reply, cs commands, name string) (string, error) {
+	if name == "" {
+		return r.render("help", map[string]any{"Commands": cs.names(), "Help": cs.helpUsage()}), nil
+	}
+
+	cmd := cs.find(name)
+	if cmd == nil {
+		return r.render("error.no_command", map[string]any{"Command": name, "Help": cs.helpUsage()}), nil
+	}
+
+	return r.render("help.command", map[string]any{
+		"Name":        cmd.Name,
+		"Usage":       cmd.usage(),
+		"Description": render(r.Lang, "help."+cmd.Name, nil),
+		"Aliases":     cmd.Aliases,
+		"Examples":    cmd.examples(),
+	}), nil
+}
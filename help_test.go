@@ -0,0 +1,138 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gowon-irc/go-gowon"
+	"github.com/stretchr/testify/assert"
+)
+
+var updateE2E = flag.Bool("update-e2e", false, "rewrite testdata/e2e.txt from the command registry")
+
+func TestHelpName(t *testing.T) {
+	cases := map[string]struct {
+		cmd      *command
+		expected string
+	}{
+		"no aliases": {
+			cmd:      &command{Name: "party"},
+			expected: "party",
+		},
+		"abbreviation": {
+			cmd:      &command{Name: "recent", Aliases: []string{"r"}},
+			expected: "[r]ecent",
+		},
+		"other alias": {
+			cmd:      &command{Name: "gamepass", Aliases: []string{"gp"}},
+			expected: "gamepass",
+		},
+		"abbreviation after another alias": {
+			cmd:      &command{Name: "next", Aliases: []string{"nextall", "n"}},
+			expected: "[n]ext",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, helpName(tc.cmd))
+		})
+	}
+}
+
+func TestHelpHandler(t *testing.T) {
+	cmds := xblCommands(nil, nil, nil, nil)
+
+	cases := map[string]struct {
+		name     string
+		expected string
+	}{
+		"command": {
+			name:     "recent",
+			expected: ".xbl recent [user] [window] [played|score] [--window <window>] [--order <played|score>] | list recently played games, by when they were played or by gamerscore earned | aliases: r | e.g. .xbl recent dave 7d score, .xbl recent --window 2w @sam",
+		},
+		"alias": {
+			name:     "o",
+			expected: ".xbl online | show which linked players in the channel are online and what they're playing | aliases: o",
+		},
+		"unknown command": {
+			name:     "nope",
+			expected: "Error: there's no nope command, see .xbl help [command]",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			out, err := helpHandler(testReply(), cmds, tc.name)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, out)
+		})
+	}
+
+	out, err := helpHandler(testReply(), cmds, "")
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(out, "xbox live commands: [s]et, [r]ecent, [l]ast, [a]chievement, "), out)
+	assert.True(t, strings.HasSuffix(out, ", tz, help | .xbl help [command] shows how to use one"), out)
+}
+
+func TestXblCommandsHelp(t *testing.T) {
+	for _, cmd := range xblCommands(nil, nil, nil, nil) {
+		for _, lang := range languages {
+			assert.NotNil(t, templateSets[lang].Lookup("help."+cmd.Name), "%s has no help in %s", cmd.Name, lang)
+		}
+	}
+}
+
+// e2eLines is what e2e.sh expects the module to reply to messages that don't
+// need xbox live, one "input|expected output" per line.
+func e2eLines(t *testing.T) string {
+	kv := openTestKV(t)
+	noop := func(ms gowon.Message, v any) {}
+	handler := genXblHandler(nil, nil, kv, noop, noop)
+
+	inputs := []string{"invalid command", "help"}
+	for _, cmd := range xblCommands(nil, nil, nil, nil) {
+		inputs = append(inputs, "help "+cmd.Name)
+	}
+
+	var b strings.Builder
+	for _, in := range inputs {
+		out, err := handler(gowon.Message{Nick: "tester", Dest: "#gowon", Args: in})
+		if err != nil {
+			t.Fatalf("failed to handle %s: %s", in, err)
+		}
+
+		if strings.Contains(out, "\n") {
+			t.Fatalf("reply to %s is more than one line", in)
+		}
+
+		fmt.Fprintf(&b, ".xbl %s|%s\n", in, out)
+	}
+
+	return b.String()
+}
+
+// TestE2ELines checks testdata/e2e.txt matches the command registry. Run
+// with -update-e2e to regenerate it after changing a command.
+func TestE2ELines(t *testing.T) {
+	fp := filepath.Join("testdata", "e2e.txt")
+	expected := e2eLines(t)
+
+	if *updateE2E {
+		err := os.WriteFile(fp, []byte(expected), 0644)
+		if err != nil {
+			t.Fatalf("failed to write %s: %s", fp, err)
+		}
+	}
+
+	actual, err := os.ReadFile(fp)
+	if err != nil {
+		t.Fatalf("failed to read %s: %s", fp, err)
+	}
+
+	assert.Equal(t, expected, string(actual), "run go test -run TestE2ELines -update-e2e to regenerate it")
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/boltdb/bolt"
+)
+
+var invalidLanguageErr = errors.New("invalid language")
+
+// languages lists the translations in templates/.
+var languages = []string{"en", "de"}
+
+// thousandsSeparators is placed between every three digits of a number.
+var thousandsSeparators = map[string]string{
+	"en": ",",
+	"de": ".",
+}
+
+// defaultLanguage is used in channels that haven't picked a language.
+var defaultLanguage = "en"
+
+// parseLanguage normalises a language code such as en-GB or DE.
+func parseLanguage(lang string) (string, error) {
+	lang = strings.ToLower(lang)
+	lang, _, _ = strings.Cut(lang, "-")
+
+	if !slices.Contains(languages, lang) {
+		return "", invalidLanguageErr
+	}
+
+	return lang, nil
+}
+
+// pluralForm picks between the singular and plural form of a word for n. Both
+// languages supported so far only use the singular for exactly one.
+func pluralForm(lang string, n int, one, other string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, one)
+	}
+
+	return fmt.Sprintf("%s %s", formatNumber(lang, n), other)
+}
+
+// formatNumber groups the thousands of n, which may be an int or a string of
+// digits such as the gamerscore in a player summary.
+func formatNumber(lang string, n any) string {
+	var s string
+
+	switch v := n.(type) {
+	case int:
+		s = strconv.Itoa(v)
+	case string:
+		if _, err := strconv.Atoi(v); err != nil {
+			return v
+		}
+		s = v
+	default:
+		return fmt.Sprint(n)
+	}
+
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+
+	sep, ok := thousandsSeparators[lang]
+	if !ok {
+		sep = thousandsSeparators[defaultLanguage]
+	}
+
+	var sb strings.Builder
+	for i, r := range s {
+		if i > 0 && (len(s)-i)%3 == 0 {
+			sb.WriteString(sep)
+		}
+		sb.WriteRune(r)
+	}
+
+	return sign + sb.String()
+}
+
+// channelLanguage returns the language picked for channel, or the default.
+func channelLanguage(kv *bolt.DB, channel string) (string, error) {
+	lang, err := getChannelSetting(kv, channel, "language")
+	if err != nil || lang == "" {
+		return defaultLanguage, err
+	}
+
+	return lang, nil
+}
+
+func languageHandler(kv *bolt.DB, r *reply, channel, code string) (string, error) {
+	if code == "" {
+		return r.render("language", map[string]any{"Channel": channel, "Language": r.Lang, "Languages": languages}), nil
+	}
+
+	l, err := parseLanguage(code)
+	if errors.Is(err, invalidLanguageErr) {
+		return r.render("error.bad_language", map[string]any{"Language": code, "Languages": languages}), nil
+	}
+
+	err = setChannelSetting(kv, channel, "language", l)
+	if err != nil {
+		return "", err
+	}
+
+	// the confirmation is already in the new language
+	r.Lang = l
+
+	return r.render("language.set", map[string]any{"Channel": channel, "Language": l}), nil
+}
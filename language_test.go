@@ -0,0 +1,152 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLanguage(t *testing.T) {
+	cases := map[string]struct {
+		in       string
+		expected string
+		err      error
+	}{
+		"english": {
+			in:       "en",
+			expected: "en",
+		},
+		"upper case": {
+			in:       "DE",
+			expected: "de",
+		},
+		"region": {
+			in:       "de-AT",
+			expected: "de",
+		},
+		"unsupported": {
+			in:  "fr",
+			err: invalidLanguageErr,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			out, err := parseLanguage(tc.in)
+			assert.Equal(t, tc.expected, out)
+			assert.ErrorIs(t, err, tc.err)
+		})
+	}
+}
+
+func TestFormatNumber(t *testing.T) {
+	cases := map[string]struct {
+		lang     string
+		in       any
+		expected string
+	}{
+		"small": {
+			lang:     "en",
+			in:       999,
+			expected: "999",
+		},
+		"english thousands": {
+			lang:     "en",
+			in:       19165,
+			expected: "19,165",
+		},
+		"german thousands": {
+			lang:     "de",
+			in:       19165,
+			expected: "19.165",
+		},
+		"millions": {
+			lang:     "en",
+			in:       1234567,
+			expected: "1,234,567",
+		},
+		"negative": {
+			lang:     "en",
+			in:       -1500,
+			expected: "-1,500",
+		},
+		"string": {
+			lang:     "de",
+			in:       "41020",
+			expected: "41.020",
+		},
+		"not a number": {
+			lang:     "en",
+			in:       "lots",
+			expected: "lots",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, formatNumber(tc.lang, tc.in))
+		})
+	}
+}
+
+func TestPluralForm(t *testing.T) {
+	cases := map[string]struct {
+		lang     string
+		n        int
+		expected string
+	}{
+		"none": {
+			lang:     "en",
+			n:        0,
+			expected: "0 days",
+		},
+		"one": {
+			lang:     "en",
+			n:        1,
+			expected: "1 day",
+		},
+		"many": {
+			lang:     "de",
+			n:        1200,
+			expected: "1.200 days",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, pluralForm(tc.lang, tc.n, "day", "days"))
+		})
+	}
+}
+
+func TestLanguageHandler(t *testing.T) {
+	kv := openTestKV(t)
+
+	out, err := languageHandler(kv, testReply(), "#gowon", "")
+	assert.Nil(t, err)
+	assert.Equal(t, "language for #gowon is {cyan}en{clear} (one of en, de)", out)
+
+	out, err = languageHandler(kv, testReply(), "#gowon", "fr")
+	assert.Nil(t, err)
+	assert.Equal(t, "Error: fr isn't a supported language, use one of en, de", out)
+
+	out, err = languageHandler(kv, testReply(), "#gowon", "DE")
+	assert.Nil(t, err)
+	assert.Equal(t, "Sprache für #gowon auf {cyan}de{clear} gesetzt", out)
+
+	lang, err := channelLanguage(kv, "#gowon")
+	assert.Nil(t, err)
+	assert.Equal(t, "de", lang)
+
+	lang, err = channelLanguage(kv, "#other")
+	assert.Nil(t, err)
+	assert.Equal(t, "en", lang)
+
+	oldLength, oldLines := maxLineLength, maxLines
+	t.Cleanup(func() { maxLineLength, maxLines = oldLength, oldLines })
+	maxLineLength, maxLines = 20, 1
+
+	out, err = renderChannelOutput(kv, "#gowon", "aaaa, bbbb, cccc, dddd")
+	assert.Nil(t, err)
+	assert.Equal(t, "aaaa, +3 weitere", out)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/imroc/req/v3"
+)
+
+const (
+	lookupNick     = "nick"
+	lookupGamertag = "gamertag"
+)
+
+const (
+	// choiceWindow is how long a nick has to pick from the players their
+	// set matched.
+	choiceWindow = 5 * time.Minute
+	// maxCandidates is how many matching players are offered or listed.
+	maxCandidates = 5
+)
+
+var nickNotSetErr = errors.New("nick hasn't set a user")
+
+// XBLLookup is how the user passed to a command was found: the linked user
+// of an irc nick, or a gamertag search.
+type XBLLookup struct {
+	Via      string `json:"via"`
+	Nick     string `json:"nick,omitempty"`
+	GamerTag string `json:"gamertag"`
+	Xuid     string `json:"xuid"`
+}
+
+// parseUser splits the way user should be looked up from its name. nick:
+// and @ force a linked nick, gt: forces a gamertag search, and without
+// either both are tried.
+func parseUser(user string) (lookup, name string) {
+	if name, ok := strings.CutPrefix(user, "@"); ok {
+		return lookupNick, name
+	}
+
+	prefix, name, ok := strings.Cut(user, ":")
+	if !ok {
+		return "", user
+	}
+
+	switch strings.ToLower(prefix) {
+	case "nick":
+		return lookupNick, name
+	case "gt":
+		return lookupGamertag, name
+	}
+
+	return "", user
+}
+
+// lookupUser finds the xbox live user meant by user, preferring an irc nick
+// that has linked one over a gamertag that happens to match.
+func lookupUser(client *req.Client, kv *bolt.DB, user string) (XBLLookup, error) {
+	lookup, name := parseUser(user)
+
+	if lookup != lookupGamertag {
+		gamerTag, xuid, err := getUser(kv, []byte(name))
+		if err != nil {
+			return XBLLookup{}, err
+		}
+
+		if len(xuid) != 0 {
+			return XBLLookup{Via: lookupNick, Nick: name, GamerTag: string(gamerTag), Xuid: string(xuid)}, nil
+		}
+
+		if lookup == lookupNick {
+			return XBLLookup{}, nickNotSetErr
+		}
+	}
+
+	xuid, gamerTag, err := xblGetXuid(client, name)
+	if err != nil {
+		return XBLLookup{}, err
+	}
+
+	return XBLLookup{Via: lookupGamertag, GamerTag: gamerTag, Xuid: xuid}, nil
+}
+
+// getChoices returns the players nick can pick from with set, unless they
+// were offered too long ago.
+func getChoices(kv *bolt.DB, nick string) ([]userCandidate, error) {
+	candidates := []userCandidate{}
+
+	found, err := cacheGet(kv, "xboxlive_choices", nick, choiceWindow, &candidates)
+	if err != nil || !found {
+		return nil, err
+	}
+
+	return candidates, nil
+}
+
+func deleteChoices(kv *bolt.DB, nick string) error {
+	return cacheDelete(kv, "xboxlive_choices", nick)
+}
+
+// chooseUser offers nick the first few candidates for user, numbered so one
+// can be picked with set.
+func chooseUser(kv *bolt.DB, r *reply, nick, user string, candidates []userCandidate) (string, error) {
+	more := 0
+	if len(candidates) > maxCandidates {
+		more = len(candidates) - maxCandidates
+		candidates = candidates[:maxCandidates]
+	}
+
+	err := cachePut(kv, "xboxlive_choices", nick, candidates)
+	if err != nil {
+		return "", err
+	}
+
+	type choice struct {
+		N    int
+		Name string
+	}
+
+	choices := []choice{}
+	for i, c := range candidates {
+		choices = append(choices, choice{i + 1, c.Name})
+	}
+
+	return r.render("set.choose", map[string]any{
+		"User":    user,
+		"Choices": choices,
+		"More":    more,
+		"Usage":   fmt.Sprintf("%sxbl set <n>", commandPrefix),
+		"Minutes": int(choiceWindow.Minutes()),
+	}), nil
+}
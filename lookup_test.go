@@ -0,0 +1,237 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/imroc/req/v3"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// searchURL matches a gamertag search for anyone.
+const searchURL = `=~^https://xbl\.io/api/v2/search/`
+
+func TestParseUser(t *testing.T) {
+	cases := map[string]struct {
+		in     string
+		lookup string
+		name   string
+	}{
+		"plain": {
+			in:   "dave",
+			name: "dave",
+		},
+		"mention": {
+			in:     "@dave",
+			lookup: lookupNick,
+			name:   "dave",
+		},
+		"nick": {
+			in:     "NICK:dave",
+			lookup: lookupNick,
+			name:   "dave",
+		},
+		"gamertag": {
+			in:     "gt:Major Nelson",
+			lookup: lookupGamertag,
+			name:   "Major Nelson",
+		},
+		"other prefix": {
+			in:   "irc:dave",
+			name: "irc:dave",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			lookup, name := parseUser(tc.in)
+			assert.Equal(t, tc.lookup, lookup)
+			assert.Equal(t, tc.name, name)
+		})
+	}
+}
+
+func TestLookupUser(t *testing.T) {
+	kv := openTestKV(t)
+
+	err := setUser(kv, []byte("dave"), []byte("DaveTag"), []byte("123"))
+	assert.Nil(t, err)
+
+	cases := map[string]struct {
+		user     string
+		xblxs    string
+		expected XBLLookup
+		err      error
+	}{
+		"linked nick": {
+			user:     "dave",
+			expected: XBLLookup{Via: lookupNick, Nick: "dave", GamerTag: "DaveTag", Xuid: "123"},
+		},
+		"mention": {
+			user:     "@dave",
+			expected: XBLLookup{Via: lookupNick, Nick: "dave", GamerTag: "DaveTag", Xuid: "123"},
+		},
+		"unlinked nick": {
+			user: "nick:sam",
+			err:  nickNotSetErr,
+		},
+		"forced gamertag": {
+			user:     "gt:xTACTICSx",
+			xblxs:    "user_exists.json",
+			expected: XBLLookup{Via: lookupGamertag, GamerTag: "xTACTICSx", Xuid: "2533274798129181"},
+		},
+		"gamertag after nick": {
+			user:     "xtacticsx",
+			xblxs:    "user_exists.json",
+			expected: XBLLookup{Via: lookupGamertag, GamerTag: "xTACTICSx", Xuid: "2533274798129181"},
+		},
+		"several users": {
+			user:  "tactics",
+			xblxs: "user_exists.json",
+			err:   ambiguousUserErr,
+		},
+		"nobody": {
+			user:  "sam",
+			xblxs: "user_doesnt_exist.json",
+			err:   userNotFoundErr,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			client := req.C()
+			httpmock.ActivateNonDefault(client.GetClient())
+			if tc.xblxs != "" {
+				xblxsjson := openTestFile(t, "XBLXuidSearch", tc.xblxs)
+				httpmock.RegisterResponder("GET", searchURL, func(request *http.Request) (*http.Response, error) {
+					return httpmock.NewBytesResponse(http.StatusOK, xblxsjson), nil
+				})
+			}
+
+			l, err := lookupUser(client, kv, tc.user)
+			assert.Equal(t, tc.expected, l)
+			assert.ErrorIs(t, err, tc.err)
+		})
+	}
+}
+
+func TestCommandHandlerLookup(t *testing.T) {
+	kv := openTestKV(t)
+
+	err := setUser(kv, []byte("dave"), []byte("DaveTag"), []byte("123"))
+	assert.Nil(t, err)
+
+	client := req.C()
+	httpmock.ActivateNonDefault(client.GetClient())
+	xblxsjson := openTestFile(t, "XBLXuidSearch", "user_exists.json")
+	httpmock.RegisterResponder("GET", searchURL, func(request *http.Request) (*http.Response, error) {
+		return httpmock.NewBytesResponse(http.StatusOK, xblxsjson), nil
+	})
+
+	f := func(client *req.Client, r *reply, gamerTag, xuid string) (string, error) {
+		return gamerTag + " " + xuid, nil
+	}
+
+	cases := map[string]struct {
+		nick     string
+		user     string
+		expected string
+		lookup   *XBLLookup
+	}{
+		"self": {
+			nick:     "dave",
+			expected: "DaveTag 123",
+		},
+		"self without a link": {
+			nick:     "sam",
+			expected: "Error: username needed",
+		},
+		"nick": {
+			nick:     "sam",
+			user:     "dave",
+			expected: "[dave's linked account] DaveTag 123",
+			lookup:   &XBLLookup{Via: lookupNick, Nick: "dave", GamerTag: "DaveTag", Xuid: "123"},
+		},
+		"unlinked mention": {
+			nick:     "dave",
+			user:     "@lee",
+			expected: "Error: lee hasn't set a user",
+		},
+		"several users": {
+			nick:     "dave",
+			user:     "gt:tactics",
+			expected: "Error: tactics matches several players: {green}xTACTICSx{clear}, {red}xTACTICSx#6152{clear}, {blue}xTacTiCs xTreme{clear}, {orange}xtacticsx#4475{clear}, {magenta}xtacticsx13{clear}, +4 more, use gt: and one of their full gamertags",
+		},
+		"gamertag": {
+			nick:     "dave",
+			user:     "gt:xTACTICSx",
+			expected: "[gamertag search] xTACTICSx 2533274798129181",
+			lookup:   &XBLLookup{Via: lookupGamertag, GamerTag: "xTACTICSx", Xuid: "2533274798129181"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			r := testReply()
+			out, err := CommandHandler(client, kv, r, tc.nick, tc.user, f)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, out)
+			assert.Equal(t, tc.lookup, r.Lookup)
+		})
+	}
+}
+
+func TestSetUserHandlerChoices(t *testing.T) {
+	now := time.Date(2024, 2, 5, 12, 0, 0, 0, time.UTC)
+	setTimeNow(t, now)
+
+	kv := openTestKV(t)
+
+	client := req.C()
+	httpmock.ActivateNonDefault(client.GetClient())
+	xblxsjson := openTestFile(t, "XBLXuidSearch", "user_exists.json")
+	httpmock.RegisterResponder("GET", searchURL, func(request *http.Request) (*http.Response, error) {
+		return httpmock.NewBytesResponse(http.StatusOK, xblxsjson), nil
+	})
+
+	choose := "tactics matches several players: {green}1. xTACTICSx{clear}, {red}2. xTACTICSx#6152{clear}, {blue}3. xTacTiCs xTreme{clear}, {orange}4. xtacticsx#4475{clear}, {magenta}5. xtacticsx13{clear}, +4 more | pick one with .xbl set <n> within 5 minutes"
+
+	out, err := setUserHandler(client, kv, testReply(), "dave", "tactics")
+	assert.Nil(t, err)
+	assert.Equal(t, choose, out)
+
+	out, err = setUserHandler(client, kv, testReply(), "dave", "7")
+	assert.Nil(t, err)
+	assert.Equal(t, "Error: pick a number from 1 to 5", out)
+
+	out, err = setUserHandler(client, kv, testReply(), "dave", "2")
+	assert.Nil(t, err)
+	assert.Equal(t, "set dave's user to xEVIL TACTICSx (2533274891591060)", out)
+
+	gamerTag, xuid, err := getUser(kv, []byte("dave"))
+	assert.Nil(t, err)
+	assert.Equal(t, "xEVIL TACTICSx", string(gamerTag))
+	assert.Equal(t, "2533274891591060", string(xuid))
+
+	// once picked, a number is searched for like any other gamertag
+	out, err = setUserHandler(client, kv, testReply(), "dave", "2")
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(out, "2 matches several players:"), out)
+
+	// choices are only kept for a while
+	_, err = setUserHandler(client, kv, testReply(), "sam", "tactics")
+	assert.Nil(t, err)
+
+	setTimeNow(t, now.Add(choiceWindow+time.Second))
+
+	out, err = setUserHandler(client, kv, testReply(), "sam", "1")
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(out, "1 matches several players:"), out)
+
+	_, xuid, err = getUser(kv, []byte("sam"))
+	assert.Nil(t, err)
+	assert.Empty(t, xuid)
+}
@@ -1,11 +1,13 @@
 package main
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
@@ -22,6 +24,17 @@ type Options struct {
 	Broker string `short:"b" long:"broker" env:"GOWON_BROKER" default:"localhost:1883" description:"mqtt broker"`
 	APIKey string `short:"k" long:"api-key" env:"GOWON_XBOXLIVE_API_KEY" required:"true" description:"openxbl api key"`
 	KVPath string `short:"K" long:"kv-path" env:"GOWON_XBOXLIVE_KV_PATH" default:"kv.db" description:"path to kv db"`
+
+	PollInterval  time.Duration `short:"i" long:"poll-interval" env:"GOWON_XBOXLIVE_POLL_INTERVAL" default:"15m" description:"how often to poll linked players for completions and recaps, each poll fetching every linked player's title history plus achievements for titles with new unlocks, 0 disables polling"`
+	RecentWindow  time.Duration `short:"w" long:"recent-window" env:"GOWON_XBOXLIVE_RECENT_WINDOW" default:"720h" description:"how recently a game must have been played to count as recent"`
+	Output        string        `short:"o" long:"output" env:"GOWON_XBOXLIVE_OUTPUT" default:"gowon-markup" choice:"gowon-markup" choice:"mirc" choice:"ansi" choice:"plain" description:"how replies are coloured unless a channel picks its own output mode"`
+	MaxLineLength int           `long:"max-line-length" env:"GOWON_XBOXLIVE_MAX_LINE_LENGTH" default:"400" description:"longest reply line in bytes before it's split, 0 disables splitting"`
+	MaxLines      int           `long:"max-lines" env:"GOWON_XBOXLIVE_MAX_LINES" default:"3" description:"most lines a long reply line is split into, 0 for no limit"`
+	Language      string        `short:"L" long:"language" env:"GOWON_XBOXLIVE_LANGUAGE" default:"en" choice:"en" choice:"de" description:"language of replies unless a channel picks its own"`
+	Templates     string        `short:"T" long:"templates" env:"GOWON_XBOXLIVE_TEMPLATES" description:"path to a file of templates overriding the default replies"`
+	ProfileTopic  string        `long:"profile-topic" env:"GOWON_XBOXLIVE_PROFILE_TOPIC" default:"/gowon/xboxlive/profile" description:"mqtt topic for structured profile data"`
+	ReplyTopic    string        `long:"reply-topic" env:"GOWON_XBOXLIVE_REPLY_TOPIC" default:"/gowon/xboxlive/reply" description:"mqtt topic for structured data behind every reply, empty disables it"`
+	RecapSchedule string        `short:"R" long:"recap-schedule" env:"GOWON_XBOXLIVE_RECAP_SCHEDULE" default:"0 18 * * 0" description:"cron schedule for the weekly recap, empty disables it"`
 }
 
 const (
@@ -30,6 +43,20 @@ const (
 	mqttDisconnectTimeout    = 1000
 )
 
+func createBuckets(kv *bolt.DB) error {
+	for _, bucket := range []string{"xboxlive_xuid", "xboxlive_gamertag", "xboxlive_channel", "xboxlive_titlehistory", "xboxlive_gamepass", "xboxlive_titleinfo", "xboxlive_snapshot", "xboxlive_completed", "xboxlive_history", "xboxlive_unlocks", "xboxlive_settings", "xboxlive_usersettings", "xboxlive_choices"} {
+		err := kv.Update(func(tx *bolt.Tx) error {
+			_, err := tx.CreateBucketIfNotExists([]byte(bucket))
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func setUser(kv *bolt.DB, nick, gamerTag, xuid []byte) error {
 	err := kv.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte("xboxlive_xuid"))
@@ -63,85 +90,632 @@ func getUser(kv *bolt.DB, nick []byte) (gamerTag, user []byte, err error) {
 	return gamerTag, user, err
 }
 
-func parseArgs(msg string) (command, user string) {
-	fields := strings.Fields(msg)
+func addChannelNick(kv *bolt.DB, channel, nick []byte) error {
+	return kv.Update(func(tx *bolt.Tx) error {
+		b, err := tx.Bucket([]byte("xboxlive_channel")).CreateBucketIfNotExists(channel)
+		if err != nil {
+			return err
+		}
+		return b.Put(nick, []byte{})
+	})
+}
+
+func getChannels(kv *bolt.DB) (channels []string, err error) {
+	channels = []string{}
 
-	if len(fields) >= 1 {
-		command = fields[0]
-	}
+	err = kv.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("xboxlive_channel")).ForEach(func(k, v []byte) error {
+			channels = append(channels, string(k))
+			return nil
+		})
+	})
 
-	if len(fields) >= 2 {
-		user = fields[1]
-	}
+	return channels, err
+}
+
+func getChannelNicks(kv *bolt.DB, channel []byte) (nicks []string, err error) {
+	nicks = []string{}
+
+	err = kv.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("xboxlive_channel")).Bucket(channel)
+		if b == nil {
+			return nil
+		}
+
+		return b.ForEach(func(k, v []byte) error {
+			nicks = append(nicks, string(k))
+			return nil
+		})
+	})
 
-	return command, user
+	return nicks, err
 }
 
-func setUserHandler(client *req.Client, kv *bolt.DB, nick, user string) (string, error) {
+// setUserHandler links nick to the player user names. When several players
+// could be meant they're listed, and nick can pick one by number for a
+// while with another set.
+func setUserHandler(client *req.Client, kv *bolt.DB, r *reply, nick, user string) (string, error) {
 	if user == "" {
-		return "Error: username needed", nil
+		return r.render("error.username_needed", nil), nil
+	}
+
+	if n, err := strconv.Atoi(user); err == nil {
+		candidates, err := getChoices(kv, nick)
+		if err != nil {
+			return "", err
+		}
+
+		if len(candidates) != 0 {
+			if n < 1 || n > len(candidates) {
+				return r.render("error.bad_choice", map[string]any{"Max": len(candidates)}), nil
+			}
+
+			err = deleteChoices(kv, nick)
+			if err != nil {
+				return "", err
+			}
+
+			c := candidates[n-1]
+			return linkUser(kv, r, nick, c.GamerTag, c.Xuid)
+		}
 	}
 
 	xuid, gamerTag, err := xblGetXuid(client, user)
 	if errors.Is(userNotFoundErr, err) {
-		return fmt.Sprintf("Error: no user found for %s", user), nil
+		return r.render("error.no_user", map[string]any{"User": user}), nil
+	}
+	var ae *ambiguousUserError
+	if errors.As(err, &ae) {
+		return chooseUser(kv, r, nick, user, ae.Candidates)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return linkUser(kv, r, nick, gamerTag, xuid)
+}
+
+func linkUser(kv *bolt.DB, r *reply, nick, gamerTag, xuid string) (string, error) {
+	err := setUser(kv, []byte(nick), []byte(gamerTag), []byte(xuid))
+	if err != nil {
+		return "", err
+	}
+
+	return r.render("set", map[string]any{"Nick": nick, "GamerTag": gamerTag, "Xuid": xuid}), nil
+}
+
+// channelPlayers maps the xuid of every linked player seen in channel to
+// their nick.
+func channelPlayers(kv *bolt.DB, channel string) (map[string]string, error) {
+	nicks, err := getChannelNicks(kv, []byte(channel))
+	if err != nil {
+		return nil, err
+	}
+
+	players := map[string]string{}
+
+	for _, nick := range nicks {
+		_, xuid, err := getUser(kv, []byte(nick))
+		if err != nil {
+			return nil, err
+		}
+
+		if len(xuid) != 0 {
+			players[string(xuid)] = nick
+		}
 	}
+
+	return players, nil
+}
+
+func playerHistories(client *req.Client, kv *bolt.DB, players map[string]string) (map[string]*XBLTitleHistory, error) {
+	histories := map[string]*XBLTitleHistory{}
+
+	for xuid, nick := range players {
+		h, err := cachedTitleHistory(client, kv, xuid)
+		if err != nil {
+			return nil, err
+		}
+
+		histories[nick] = h
+	}
+
+	return histories, nil
+}
+
+func onlineHandler(client *req.Client, kv *bolt.DB, r *reply, channel string) (string, error) {
+	players, err := channelPlayers(kv, channel)
 	if err != nil {
 		return "", err
 	}
 
-	err = setUser(kv, []byte(nick), []byte(gamerTag), []byte(xuid))
+	if len(players) == 0 {
+		return r.render("error.no_players", map[string]any{"Channel": channel}), nil
+	}
+
+	return xblOnline(client, r, players)
+}
+
+func partyHandler(client *req.Client, kv *bolt.DB, r *reply, channel string) (string, error) {
+	players, err := channelPlayers(kv, channel)
 	if err != nil {
 		return "", err
 	}
 
-	return fmt.Sprintf("set %s's user to %s (%s)", nick, gamerTag, xuid), nil
+	if len(players) == 0 {
+		return r.render("error.no_players", map[string]any{"Channel": channel}), nil
+	}
+
+	return xblParty(client, r, players)
 }
 
-type commandFunc func(*req.Client, string, string) (string, error)
+func commonHandler(client *req.Client, kv *bolt.DB, r *reply, channel string, nicks []string) (string, error) {
+	players := map[string]string{}
+
+	for _, nick := range nicks {
+		_, xuid, err := getUser(kv, []byte(nick))
+		if err != nil {
+			return "", err
+		}
 
-func CommandHandler(client *req.Client, kv *bolt.DB, nick, user string, f commandFunc) (string, error) {
-	if user != "" {
-		xuid, gamerTag, err := xblGetXuid(client, user)
-		if errors.Is(userNotFoundErr, err) {
-			return fmt.Sprintf("Error: no user found for %s", user), nil
+		if len(xuid) == 0 {
+			return r.render("error.nick_not_set", map[string]any{"Nick": nick}), nil
 		}
+
+		players[string(xuid)] = nick
+	}
+
+	if len(nicks) == 0 {
+		var err error
+		players, err = channelPlayers(kv, channel)
 		if err != nil {
 			return "", err
 		}
-		return f(client, gamerTag, xuid)
 	}
 
-	gamerTag, xuid, err := getUser(kv, []byte(nick))
+	if len(players) < 2 {
+		return r.render("error.two_players_needed", nil), nil
+	}
+
+	histories, err := playerHistories(client, kv, players)
+	if err != nil {
+		return "", err
+	}
+
+	return xblCommon(r, histories), nil
+}
+
+func gamePassHandler(client *req.Client, kv *bolt.DB, r *reply, title string) (string, error) {
+	catalogue, err := cachedGamePassCatalogue(client, kv)
 	if err != nil {
 		return "", err
 	}
 
-	if len(xuid) == 0 {
-		return "Error: username needed", nil
+	return xblGamePass(r, catalogue, title), nil
+}
+
+func whoPlaysHandler(client *req.Client, kv *bolt.DB, r *reply, channel, game string) (string, error) {
+	if game == "" {
+		return r.render("error.game_needed", nil), nil
+	}
+
+	players, err := channelPlayers(kv, channel)
+	if err != nil {
+		return "", err
+	}
+
+	if len(players) == 0 {
+		return r.render("error.no_players", map[string]any{"Channel": channel}), nil
+	}
+
+	histories, err := playerHistories(client, kv, players)
+	if err != nil {
+		return "", err
 	}
 
-	return f(client, string(gamerTag), string(xuid))
+	return xblWhoPlays(r, histories, game), nil
 }
 
-func genXblHandler(client *req.Client, kv *bolt.DB) func(m gowon.Message) (string, error) {
+func infoHandler(client, catalogClient *req.Client, kv *bolt.DB, r *reply, channel, game string) (string, error) {
+	if game == "" {
+		return r.render("error.game_needed", nil), nil
+	}
+
+	players, err := channelPlayers(kv, channel)
+	if err != nil {
+		return "", err
+	}
+
+	histories, err := playerHistories(client, kv, players)
+	if err != nil {
+		return "", err
+	}
+
+	matches := matchPlayedTitles(histories, game)
+
+	if len(matches) > 1 {
+		return titleMatchError(r, "", game, matches), nil
+	}
+
+	if len(matches) == 1 {
+		info, err := cachedTitleInfo(client, catalogClient, kv, matches[0])
+		if err != nil {
+			return "", err
+		}
+
+		return xblTitleInfo(r, info, matches[0].GamePass.IsGamePass), nil
+	}
+
+	// nobody in the channel has played it, so fall back to game pass
+	catalogue, err := cachedGamePassCatalogue(catalogClient, kv)
+	if err != nil {
+		return "", err
+	}
+
+	products := catalogue.Match(game)
+
+	if len(products) > 1 {
+		return multipleMatchError(r, game, productNames(products)), nil
+	}
+
+	if len(products) == 1 {
+		return xblTitleInfo(r, &XBLTitleInfo{Name: products[0].Name, ProductID: products[0].ProductID}, true), nil
+	}
+
+	if len(players) == 0 {
+		return r.render("error.no_players", map[string]any{"Channel": channel}), nil
+	}
+
+	return r.render("error.nobody_played", map[string]any{"Game": game}), nil
+}
+
+type commandFunc func(client *req.Client, r *reply, gamerTag, xuid string) (string, error)
+
+// CommandHandler runs f for user, found with lookupUser, and says how they
+// were found. Without a user it runs for the linked user of nick.
+func CommandHandler(client *req.Client, kv *bolt.DB, r *reply, nick, user string, f commandFunc) (string, error) {
+	if user == "" {
+		gamerTag, xuid, err := getUser(kv, []byte(nick))
+		if err != nil {
+			return "", err
+		}
+
+		if len(xuid) == 0 {
+			return r.render("error.username_needed", nil), nil
+		}
+
+		return f(client, r, string(gamerTag), string(xuid))
+	}
+
+	l, err := lookupUser(client, kv, user)
+	if errors.Is(err, nickNotSetErr) {
+		_, name := parseUser(user)
+		return r.render("error.nick_not_set", map[string]any{"Nick": name}), nil
+	}
+	if errors.Is(err, userNotFoundErr) {
+		_, name := parseUser(user)
+		return r.render("error.no_user", map[string]any{"User": name}), nil
+	}
+	var ae *ambiguousUserError
+	if errors.As(err, &ae) {
+		_, name := parseUser(user)
+		return r.render("error.ambiguous_user", map[string]any{"User": name, "Names": ae.Names(), "Limit": maxCandidates}), nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	out, err := f(client, r, l.GamerTag, l.Xuid)
+	if err != nil || out == "" {
+		return out, err
+	}
+
+	r.Lookup = &l
+
+	return render(r.Lang, "lookup", map[string]any{"Lookup": l, "Reply": out}), nil
+}
+
+// jsonFunc publishes structured data about the reply to a message.
+type jsonFunc func(ms gowon.Message, v any)
+
+// xblCommands registers every .xbl subcommand.
+func xblCommands(client, catalogClient *req.Client, kv *bolt.DB, publishProfile jsonFunc) commands {
+	// userCommand runs f for the user named by every argument, so gamertags
+	// with spaces don't need quoting.
+	userCommand := func(f commandFunc) func(c *commandCall) (string, error) {
+		return func(c *commandCall) (string, error) {
+			return CommandHandler(client, kv, c.Reply, c.Msg.Nick, c.rest(0), f)
+		}
+	}
+
+	var cmds commands
+
+	cmds = commands{
+		{
+			Name:     "set",
+			Aliases:  []string{"s"},
+			Args:     "<gamertag>|<n>",
+			Examples: []string{`"Major Nelson"`, "2"},
+			Run: func(c *commandCall) (string, error) {
+				return setUserHandler(client, kv, c.Reply, c.Msg.Nick, c.rest(0))
+			},
+		},
+		{
+			Name:     "recent",
+			Aliases:  []string{"r"},
+			Args:     "[user] [window] [played|score]",
+			Flags:    []commandFlag{{Name: "window", Value: "window"}, {Name: "order", Value: "played|score"}},
+			Examples: []string{"dave 7d score", "--window 2w @sam"},
+			Run: func(c *commandCall) (string, error) {
+				user, window, order := parseRecentArgs(c.Args)
+				if w, ok := c.flag("window"); ok {
+					var err error
+					window, err = parseWindow(w)
+					if errors.Is(err, invalidWindowErr) {
+						return c.Reply.render("error.bad_window", nil), nil
+					}
+				}
+
+				if o, ok := c.flag("order"); ok {
+					if order, ok = parseOrder(o); !ok {
+						return c.usageError(), nil
+					}
+				}
+
+				return CommandHandler(client, kv, c.Reply, c.Msg.Nick, user, func(client *req.Client, r *reply, gamerTag, xuid string) (string, error) {
+					return xblRecentGames(client, kv, r, gamerTag, xuid, window, order)
+				})
+			},
+		},
+		{
+			Name:     "last",
+			Aliases:  []string{"l"},
+			Args:     "[user]",
+			Examples: []string{"dave", "gt:Major Nelson"},
+			Run:      userCommand(xblLastGame),
+		},
+		{
+			Name:     "achievement",
+			Aliases:  []string{"a"},
+			Args:     "[user]",
+			Examples: []string{"Major Nelson"},
+			Run:      userCommand(xblLastAchievement),
+		},
+		{
+			Name:     "player",
+			Aliases:  []string{"p"},
+			Args:     "[user]",
+			Examples: []string{"nick:dave"},
+			Run:      userCommand(xblPlayerSummary),
+		},
+		{
+			Name:     "profile",
+			Args:     "[user]",
+			Examples: []string{"Major Nelson"},
+			Run: func(c *commandCall) (string, error) {
+				return CommandHandler(client, kv, c.Reply, c.Msg.Nick, c.rest(0), func(client *req.Client, r *reply, gamerTag, xuid string) (string, error) {
+					p, err := xblGetProfile(client, gamerTag, xuid)
+					if errors.Is(err, userNotFoundErr) {
+						return r.render("error.no_profile", UserData{GamerTag: gamerTag}), nil
+					}
+					if err != nil {
+						return "", err
+					}
+
+					publishProfile(c.Msg, p.Profile())
+
+					return r.render("profile", PlayerData{GamerTag: gamerTag, Player: *p}), nil
+				})
+			},
+		},
+		{
+			Name:     "game",
+			Aliases:  []string{"g"},
+			Args:     "<user> <game>",
+			MinArgs:  2,
+			Examples: []string{`"Major Nelson" halo infinite`},
+			Run: func(c *commandCall) (string, error) {
+				return CommandHandler(client, kv, c.Reply, c.Msg.Nick, c.arg(0), func(client *req.Client, r *reply, gamerTag, xuid string) (string, error) {
+					return xblGame(client, r, gamerTag, xuid, c.rest(1))
+				})
+			},
+		},
+		{
+			Name:     "rare",
+			Aliases:  []string{"chase"},
+			Args:     "[user] [game]",
+			Flags:    []commandFlag{{Name: "locked"}},
+			Examples: []string{"@dave halo", "--locked"},
+			Run: func(c *commandCall) (string, error) {
+				_, locked := c.flag("locked")
+				return CommandHandler(client, kv, c.Reply, c.Msg.Nick, c.arg(0), func(client *req.Client, r *reply, gamerTag, xuid string) (string, error) {
+					return xblRare(client, r, gamerTag, xuid, c.rest(1), locked || c.Name == "chase")
+				})
+			},
+		},
+		{
+			Name:     "next",
+			Aliases:  []string{"n", "nextall"},
+			Args:     "[user] [game]",
+			Flags:    []commandFlag{{Name: "secret"}},
+			Examples: []string{"dave forza", "--secret"},
+			Run: func(c *commandCall) (string, error) {
+				_, secret := c.flag("secret")
+				return CommandHandler(client, kv, c.Reply, c.Msg.Nick, c.arg(0), func(client *req.Client, r *reply, gamerTag, xuid string) (string, error) {
+					return xblNext(client, r, gamerTag, xuid, c.rest(1), secret || c.Name == "nextall")
+				})
+			},
+		},
+		{
+			Name:     "friends",
+			Aliases:  []string{"f"},
+			Args:     "[user]",
+			Examples: []string{"@dave"},
+			Run:      userCommand(xblFriends),
+		},
+		{
+			Name:    "online",
+			Aliases: []string{"o"},
+			Run: func(c *commandCall) (string, error) {
+				return onlineHandler(client, kv, c.Reply, c.Msg.Dest)
+			},
+		},
+		{
+			Name: "party",
+			Run: func(c *commandCall) (string, error) {
+				return partyHandler(client, kv, c.Reply, c.Msg.Dest)
+			},
+		},
+		{
+			Name:     "common",
+			Aliases:  []string{"c"},
+			Args:     "[nick...]",
+			Examples: []string{"dave sam"},
+			Run: func(c *commandCall) (string, error) {
+				nicks := []string{}
+				for _, nick := range c.Args {
+					nicks = append(nicks, strings.TrimPrefix(nick, "@"))
+				}
+				return commonHandler(client, kv, c.Reply, c.Msg.Dest, nicks)
+			},
+		},
+		{
+			Name:     "whoplays",
+			Aliases:  []string{"w"},
+			Args:     "<game>",
+			Examples: []string{"halo infinite"},
+			Run: func(c *commandCall) (string, error) {
+				return whoPlaysHandler(client, kv, c.Reply, c.Msg.Dest, c.rest(0))
+			},
+		},
+		{
+			Name:     "clips",
+			Args:     "[user]",
+			Examples: []string{"@dave"},
+			Run: userCommand(func(client *req.Client, r *reply, gamerTag, xuid string) (string, error) {
+				return xblCaptures(client, r, gamerTag, xuid, "gameclips")
+			}),
+		},
+		{
+			Name:     "shots",
+			Args:     "[user]",
+			Examples: []string{"@dave"},
+			Run: userCommand(func(client *req.Client, r *reply, gamerTag, xuid string) (string, error) {
+				return xblCaptures(client, r, gamerTag, xuid, "screenshots")
+			}),
+		},
+		{
+			Name:     "gamepass",
+			Aliases:  []string{"gp"},
+			Args:     "<game>|new|leaving",
+			Examples: []string{"starfield", "new", "leaving"},
+			Run: func(c *commandCall) (string, error) {
+				return gamePassHandler(catalogClient, kv, c.Reply, c.rest(0))
+			},
+		},
+		{
+			Name:     "info",
+			Aliases:  []string{"i"},
+			Args:     "<game>",
+			Examples: []string{"halo infinite"},
+			Run: func(c *commandCall) (string, error) {
+				return infoHandler(client, catalogClient, kv, c.Reply, c.Msg.Dest, c.rest(0))
+			},
+		},
+		{
+			Name:     "timeline",
+			Aliases:  []string{"t"},
+			Args:     "<user> <game>",
+			MinArgs:  2,
+			Examples: []string{"dave halo infinite"},
+			Run: func(c *commandCall) (string, error) {
+				return CommandHandler(client, kv, c.Reply, c.Msg.Nick, c.arg(0), func(client *req.Client, r *reply, gamerTag, xuid string) (string, error) {
+					return xblTimeline(client, r, gamerTag, xuid, c.rest(1))
+				})
+			},
+		},
+		{
+			Name:     "completed",
+			Args:     "[user]",
+			Examples: []string{"@dave"},
+			Run: userCommand(func(client *req.Client, r *reply, gamerTag, xuid string) (string, error) {
+				return xblCompleted(client, kv, r, gamerTag, xuid)
+			}),
+		},
+		{
+			Name: "recap",
+			Run: func(c *commandCall) (string, error) {
+				return recapFor(kv, c.Reply, c.Msg.Dest)
+			},
+		},
+		{
+			Name:     "output",
+			Args:     "[mode]",
+			Examples: []string{"mirc"},
+			Run: func(c *commandCall) (string, error) {
+				return outputHandler(kv, c.Reply, c.Msg.Dest, c.arg(0))
+			},
+		},
+		{
+			Name:     "language",
+			Aliases:  []string{"lang"},
+			Args:     "[language]",
+			Examples: []string{"de"},
+			Run: func(c *commandCall) (string, error) {
+				return languageHandler(kv, c.Reply, c.Msg.Dest, c.arg(0))
+			},
+		},
+		{
+			Name:     "tz",
+			Aliases:  []string{"timezone"},
+			Args:     "[channel] [zone]",
+			Examples: []string{"Europe/London", "channel America/New_York"},
+			Run: func(c *commandCall) (string, error) {
+				return tzHandler(kv, c.Reply, c.Msg.Nick, c.Msg.Dest, c.rest(0))
+			},
+		},
+		{
+			Name:     "help",
+			Args:     "[command]",
+			Examples: []string{"recent"},
+			Run: func(c *commandCall) (string, error) {
+				return helpHandler(c.Reply, cmds, c.arg(0))
+			},
+		},
+	}
+
+	return cmds
+}
+
+func genXblHandler(client, catalogClient *req.Client, kv *bolt.DB, publishProfile, publishReply jsonFunc) func(m gowon.Message) (string, error) {
+	cmds := xblCommands(client, catalogClient, kv, publishProfile)
+
 	return func(m gowon.Message) (string, error) {
-		command, user := parseArgs(m.Args)
+		err := addChannelNick(kv, []byte(m.Dest), []byte(m.Nick))
+		if err != nil {
+			return "", err
+		}
+
+		lang, err := channelLanguage(kv, m.Dest)
+		if err != nil {
+			return "", err
+		}
+
+		loc, err := userTimezone(kv, m.Nick, m.Dest)
+		if err != nil {
+			return "", err
+		}
+
+		r := newReply(lang, loc)
 
-		switch command {
-		case "s", "set":
-			return setUserHandler(client, kv, m.Nick, user)
-		case "r", "recent":
-			return CommandHandler(client, kv, m.Nick, user, xblRecentGames)
-		case "l", "last":
-			return CommandHandler(client, kv, m.Nick, user, xblLastGame)
-		case "a", "achievement":
-			return CommandHandler(client, kv, m.Nick, user, xblLastAchievement)
-		case "p", "player":
-			return CommandHandler(client, kv, m.Nick, user, xblPlayerSummary)
+		out, err := cmds.run(m, r)
+		if err != nil || out == "" {
+			return out, err
 		}
 
-		return "one of [s]et, [r]ecent, [l]ast, [a]chievements or [p]layer  must be passed as a command", nil
+		publishReply(m, newXBLReply(m, r, out))
+
+		return renderChannelOutput(kv, m.Dest, out)
 	}
 }
 
@@ -161,6 +735,76 @@ func onConnectHandler(c mqtt.Client) {
 	log.Println("connected to broker")
 }
 
+// publishLines publishes each line of out as a reply to ms.
+func publishLines(client mqtt.Client, ms gowon.Message, module, out string) {
+	for _, line := range strings.Split(out, "\n") {
+		if line == "" {
+			continue
+		}
+
+		ms.Module = module
+		ms.Msg = line
+		mb, err := json.Marshal(ms)
+		if err != nil {
+			log.Print(err)
+			return
+		}
+		client.Publish("/gowon/output", 0, false, mb)
+	}
+}
+
+// publishJSON publishes v to topic along with where the reply it belongs to
+// was sent.
+func publishJSON(client mqtt.Client, topic string, ms gowon.Message, module string, v any) {
+	publishDocument(client, topic, struct {
+		Module string `json:"module"`
+		Dest   string `json:"dest"`
+		Nick   string `json:"nick"`
+		Data   any    `json:"data"`
+	}{module, ms.Dest, ms.Nick, v})
+}
+
+// publishDocument publishes v to topic as it is, for documents such as
+// XBLReply that already say which message they belong to.
+func publishDocument(client mqtt.Client, topic string, v any) {
+	mb, err := json.Marshal(v)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	client.Publish(topic, 0, false, mb)
+}
+
+// subscribe routes messages like gowon.MessageRouter.Subscribe, but publishes
+// each line of a reply as its own message so multi-line replies reach irc.
+func subscribe(opts *mqtt.ClientOptions, mr *gowon.MessageRouter, module string) {
+	oldOnConnect := opts.OnConnect
+
+	opts.OnConnect = func(client mqtt.Client) {
+		if oldOnConnect != nil {
+			oldOnConnect(client)
+		}
+
+		client.Subscribe("/gowon/input", 0, func(client mqtt.Client, msg mqtt.Message) {
+			ms, err := gowon.CreateMessageStruct(msg.Payload())
+			if err != nil {
+				log.Print(err)
+				return
+			}
+
+			out, err := mr.Route(ms)
+			if err != nil {
+				log.Print(err)
+				return
+			}
+
+			publishLines(client, ms, module, out)
+		})
+
+		log.Print("subscription to /gowon/input complete")
+	}
+}
+
 func main() {
 	log.Printf("%s starting\n", moduleName)
 
@@ -169,6 +813,22 @@ func main() {
 		log.Fatal(err)
 	}
 
+	if opts.RecentWindow > 0 {
+		recentWindow = opts.RecentWindow
+	}
+
+	defaultOutput = opts.Output
+	maxLineLength = opts.MaxLineLength
+	maxLines = opts.MaxLines
+	defaultLanguage = opts.Language
+	commandPrefix = opts.Prefix
+
+	if opts.Templates != "" {
+		if err := loadTemplates(opts.Templates); err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	mqttOpts := mqtt.NewClientOptions()
 	mqttOpts.AddBroker(fmt.Sprintf("tcp://%s", opts.Broker))
 	mqttOpts.SetClientID(fmt.Sprintf("gowon_%s", moduleName))
@@ -187,39 +847,74 @@ func main() {
 	}
 	defer kv.Close()
 
-	for _, bucket := range []string{"xboxlive_xuid", "xboxlive_gamertag"} {
-		err = kv.Update(func(tx *bolt.Tx) error {
-			_, err := tx.CreateBucketIfNotExists([]byte(bucket))
-			return err
-		})
-		if err != nil {
-			log.Fatal(err)
-		}
+	err = createBuckets(kv)
+	if err != nil {
+		log.Fatal(err)
 	}
 
 	httpClient := req.C().
 		SetCommonHeader("x-authorization", opts.APIKey).
 		SetCommonHeader("accept", "*/*")
 
+	// catalogue lookups go to microsoft directly, so mustn't carry the api key
+	catalogClient := req.C()
+
+	var c mqtt.Client
+
+	publishProfile := func(ms gowon.Message, v any) {
+		publishJSON(c, opts.ProfileTopic, ms, moduleName, v)
+	}
+
+	publishReply := func(ms gowon.Message, v any) {
+		if opts.ReplyTopic != "" {
+			publishDocument(c, opts.ReplyTopic, v)
+		}
+	}
+
 	mr := gowon.NewMessageRouter()
-	mr.AddCommand("xbl", genXblHandler(httpClient, kv))
-	mr.Subscribe(mqttOpts, moduleName)
+	mr.AddCommand("xbl", genXblHandler(httpClient, catalogClient, kv, publishProfile, publishReply))
+	subscribe(mqttOpts, mr, moduleName)
 
 	log.Print("connecting to broker")
 
-	c := mqtt.NewClient(mqttOpts)
+	c = mqtt.NewClient(mqttOpts)
 	if token := c.Connect(); token.Wait() && token.Error() != nil {
 		panic(token.Error())
 	}
 
 	log.Print("connected to broker")
 
+	done := make(chan struct{})
+
+	announce := func(dest, msg string) {
+		out, err := renderChannelOutput(kv, dest, msg)
+		if err != nil {
+			log.Print(err)
+			return
+		}
+
+		publishLines(c, gowon.Message{Dest: dest}, moduleName, out)
+	}
+
+	if opts.PollInterval > 0 {
+		go poll(httpClient, kv, opts.PollInterval, announce, done)
+	}
+
+	if opts.RecapSchedule != "" {
+		recapCron, err := scheduleRecap(kv, opts.RecapSchedule, announce)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer recapCron.Stop()
+	}
+
 	sigs := make(chan os.Signal, 1)
 	signal.Notify(sigs, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
 
 	<-sigs
 
 	log.Println("signal caught, exiting")
+	close(done)
 	c.Disconnect(mqttDisconnectTimeout)
 	log.Println("shutdown complete")
 }
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/boltdb/bolt"
+)
+
+const (
+	outputGowon = "gowon-markup"
+	outputMirc  = "mirc"
+	outputANSI  = "ansi"
+	outputPlain = "plain"
+)
+
+var outputModes = []string{outputGowon, outputMirc, outputANSI, outputPlain}
+
+var invalidOutputErr = errors.New("invalid output mode")
+
+// defaultOutput is used in channels that haven't picked an output mode.
+var defaultOutput = outputGowon
+
+var mircColours = map[string]string{
+	"white":      "00",
+	"black":      "01",
+	"blue":       "02",
+	"green":      "03",
+	"red":        "04",
+	"brown":      "05",
+	"magenta":    "06",
+	"orange":     "07",
+	"yellow":     "08",
+	"lightgreen": "09",
+	"cyan":       "10",
+	"lightcyan":  "11",
+	"lightblue":  "12",
+	"pink":       "13",
+	"grey":       "14",
+	"lightgrey":  "15",
+}
+
+var ansiColours = map[string]string{
+	"white":      "97",
+	"black":      "30",
+	"blue":       "34",
+	"green":      "32",
+	"red":        "31",
+	"brown":      "38;5;94",
+	"magenta":    "35",
+	"orange":     "38;5;208",
+	"yellow":     "33",
+	"lightgreen": "92",
+	"cyan":       "36",
+	"lightcyan":  "96",
+	"lightblue":  "94",
+	"pink":       "95",
+	"grey":       "90",
+	"lightgrey":  "37",
+}
+
+// markupRe matches the gowon colour tags produced by colourString, and
+// nothing else in braces, so text like a bio containing {this} survives.
+var markupRe = func() *regexp.Regexp {
+	names := []string{"clear"}
+	for c := range mircColours {
+		names = append(names, c)
+	}
+	return regexp.MustCompile(`\{(` + strings.Join(names, "|") + `)\}`)
+}()
+
+// parseOutputMode normalises an output mode name.
+func parseOutputMode(mode string) (string, error) {
+	mode = strings.ToLower(mode)
+
+	switch mode {
+	case "gowon", "markup":
+		return outputGowon, nil
+	case "irc":
+		return outputMirc, nil
+	}
+
+	for _, m := range outputModes {
+		if mode == m {
+			return m, nil
+		}
+	}
+
+	return "", invalidOutputErr
+}
+
+// renderOutput converts the gowon colour markup that every reply is built
+// with into mode. It's the only place colours are turned into anything other
+// than gowon markup.
+func renderOutput(mode, in string) string {
+	switch mode {
+	case outputMirc:
+		return markupRe.ReplaceAllStringFunc(in, func(tag string) string {
+			name := tag[1 : len(tag)-1]
+			if name == "clear" {
+				return "\x0f"
+			}
+			return "\x03" + mircColours[name]
+		})
+	case outputANSI:
+		return markupRe.ReplaceAllStringFunc(in, func(tag string) string {
+			name := tag[1 : len(tag)-1]
+			if name == "clear" {
+				return "\x1b[0m"
+			}
+			return fmt.Sprintf("\x1b[%sm", ansiColours[name])
+		})
+	case outputPlain:
+		return markupRe.ReplaceAllString(in, "")
+	}
+
+	return in
+}
+
+// getSetting reads key from the settings kept for name, a channel or nick, in
+// bucket. Settings that were never set are empty.
+func getSetting(kv *bolt.DB, bucket, name, key string) (value string, err error) {
+	err = kv.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket)).Bucket([]byte(name))
+		if b == nil {
+			return nil
+		}
+
+		value = string(b.Get([]byte(key)))
+		return nil
+	})
+
+	return value, err
+}
+
+func setSetting(kv *bolt.DB, bucket, name, key, value string) error {
+	return kv.Update(func(tx *bolt.Tx) error {
+		b, err := tx.Bucket([]byte(bucket)).CreateBucketIfNotExists([]byte(name))
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(key), []byte(value))
+	})
+}
+
+func getChannelSetting(kv *bolt.DB, channel, key string) (string, error) {
+	return getSetting(kv, "xboxlive_settings", channel, key)
+}
+
+func setChannelSetting(kv *bolt.DB, channel, key, value string) error {
+	return setSetting(kv, "xboxlive_settings", channel, key, value)
+}
+
+func getUserSetting(kv *bolt.DB, nick, key string) (string, error) {
+	return getSetting(kv, "xboxlive_usersettings", nick, key)
+}
+
+func setUserSetting(kv *bolt.DB, nick, key, value string) error {
+	return setSetting(kv, "xboxlive_usersettings", nick, key, value)
+}
+
+// channelOutput returns the output mode picked for channel, or the default.
+func channelOutput(kv *bolt.DB, channel string) (string, error) {
+	mode, err := getChannelSetting(kv, channel, "output")
+	if err != nil || mode == "" {
+		return defaultOutput, err
+	}
+
+	return mode, nil
+}
+
+// renderChannelOutput renders out in the output mode picked for channel, then
+// splits lines that are too long for irc. Splitting comes last so the length
+// includes the colour codes actually sent.
+func renderChannelOutput(kv *bolt.DB, channel, out string) (string, error) {
+	mode, err := channelOutput(kv, channel)
+	if err != nil {
+		return "", err
+	}
+
+	lang, err := channelLanguage(kv, channel)
+	if err != nil {
+		return "", err
+	}
+
+	return splitReply(lang, renderOutput(mode, out), maxLineLength, maxLines), nil
+}
+
+func outputHandler(kv *bolt.DB, r *reply, channel, mode string) (string, error) {
+	if mode == "" {
+		current, err := channelOutput(kv, channel)
+		if err != nil {
+			return "", err
+		}
+
+		return r.render("output", map[string]any{"Channel": channel, "Mode": current, "Modes": outputModes}), nil
+	}
+
+	m, err := parseOutputMode(mode)
+	if errors.Is(err, invalidOutputErr) {
+		return r.render("error.bad_output", map[string]any{"Mode": mode, "Modes": outputModes}), nil
+	}
+
+	err = setChannelSetting(kv, channel, "output", m)
+	if err != nil {
+		return "", err
+	}
+
+	return r.render("output.set", map[string]any{"Channel": channel, "Mode": m}), nil
+}
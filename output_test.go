@@ -0,0 +1,86 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderOutput(t *testing.T) {
+	in := "{cyan}Lies of P{clear} | {yellow}1000/1000{clear} | bio with {braces}"
+
+	cases := map[string]struct {
+		mode     string
+		expected string
+	}{
+		"gowon markup": {
+			mode:     outputGowon,
+			expected: in,
+		},
+		"mirc": {
+			mode:     outputMirc,
+			expected: "\x0310Lies of P\x0f | \x03081000/1000\x0f | bio with {braces}",
+		},
+		"ansi": {
+			mode:     outputANSI,
+			expected: "\x1b[36mLies of P\x1b[0m | \x1b[33m1000/1000\x1b[0m | bio with {braces}",
+		},
+		"plain": {
+			mode:     outputPlain,
+			expected: "Lies of P | 1000/1000 | bio with {braces}",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, renderOutput(tc.mode, in))
+		})
+	}
+}
+
+func TestParseOutputMode(t *testing.T) {
+	cases := map[string]struct {
+		in       string
+		expected string
+		err      error
+	}{
+		"gowon markup": {in: "gowon-markup", expected: outputGowon},
+		"gowon alias":  {in: "gowon", expected: outputGowon},
+		"mirc":         {in: "mIRC", expected: outputMirc},
+		"ansi":         {in: "ANSI", expected: outputANSI},
+		"plain":        {in: "plain", expected: outputPlain},
+		"unknown":      {in: "html", err: invalidOutputErr},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			out, err := parseOutputMode(tc.in)
+			assert.Equal(t, tc.expected, out)
+			assert.ErrorIs(t, err, tc.err)
+		})
+	}
+}
+
+func TestOutputHandler(t *testing.T) {
+	kv := openTestKV(t)
+
+	out, err := outputHandler(kv, testReply(), "#gowon", "")
+	assert.Nil(t, err)
+	assert.Equal(t, "output mode for #gowon is {cyan}gowon-markup{clear} (one of gowon-markup, mirc, ansi, plain)", out)
+
+	out, err = outputHandler(kv, testReply(), "#gowon", "html")
+	assert.Nil(t, err)
+	assert.Equal(t, "Error: html isn't an output mode, use one of gowon-markup, mirc, ansi, plain", out)
+
+	out, err = outputHandler(kv, testReply(), "#gowon", "Plain")
+	assert.Nil(t, err)
+	assert.Equal(t, "output mode for #gowon set to {cyan}plain{clear}", out)
+
+	out, err = renderChannelOutput(kv, "#gowon", "{green}Halo{clear}")
+	assert.Nil(t, err)
+	assert.Equal(t, "Halo", out)
+
+	out, err = renderChannelOutput(kv, "#other", "{green}Halo{clear}")
+	assert.Nil(t, err)
+	assert.Equal(t, "{green}Halo{clear}", out)
+}
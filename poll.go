@@ -0,0 +1,360 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/imroc/req/v3"
+)
+
+const (
+	completedLimit    = 10
+	snapshotRetention = time.Hour * 24 * 35
+)
+
+type announceFunc func(dest, msg string)
+
+func getSnapshot(kv *bolt.DB, xuid string) (history *XBLTitleHistory, err error) {
+	err = kv.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket([]byte("xboxlive_snapshot")).Get([]byte(xuid))
+		if v == nil {
+			return nil
+		}
+
+		history = &XBLTitleHistory{}
+		return json.Unmarshal(v, history)
+	})
+
+	return history, err
+}
+
+// putSnapshot stores history as the player's latest snapshot, and as the
+// snapshot for today so recaps can compare against earlier days. Dated
+// snapshots older than snapshotRetention are removed.
+func putSnapshot(kv *bolt.DB, xuid string, history *XBLTitleHistory) error {
+	v, err := json.Marshal(history)
+	if err != nil {
+		return err
+	}
+
+	today := timeNow().UTC().Format(time.DateOnly)
+	cutoff := timeNow().Add(-snapshotRetention).UTC().Format(time.DateOnly)
+
+	return kv.Update(func(tx *bolt.Tx) error {
+		err := tx.Bucket([]byte("xboxlive_snapshot")).Put([]byte(xuid), v)
+		if err != nil {
+			return err
+		}
+
+		b, err := tx.Bucket([]byte("xboxlive_history")).CreateBucketIfNotExists([]byte(xuid))
+		if err != nil {
+			return err
+		}
+
+		err = b.Put([]byte(today), v)
+		if err != nil {
+			return err
+		}
+
+		c := b.Cursor()
+		for k, _ := c.First(); k != nil && string(k) < cutoff; k, _ = c.Next() {
+			err = c.Delete()
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// getSnapshotAt returns the player's snapshot from the latest day not after
+// t, or their oldest snapshot if none is that old.
+func getSnapshotAt(kv *bolt.DB, xuid string, t time.Time) (history *XBLTitleHistory, err error) {
+	day := []byte(t.UTC().Format(time.DateOnly))
+
+	err = kv.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("xboxlive_history")).Bucket([]byte(xuid))
+		if b == nil {
+			return nil
+		}
+
+		c := b.Cursor()
+		k, v := c.Seek(day)
+
+		if k == nil {
+			k, v = c.Last()
+		} else if string(k) != string(day) {
+			if pk, pv := c.Prev(); pk != nil {
+				k, v = pk, pv
+			}
+		}
+
+		if k == nil {
+			return nil
+		}
+
+		history = &XBLTitleHistory{}
+		return json.Unmarshal(v, history)
+	})
+
+	return history, err
+}
+
+func getCompletedDates(kv *bolt.DB, xuid string) (dates map[string]time.Time, err error) {
+	dates = map[string]time.Time{}
+
+	err = kv.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("xboxlive_completed")).Bucket([]byte(xuid))
+		if b == nil {
+			return nil
+		}
+
+		return b.ForEach(func(k, v []byte) error {
+			d, err := time.Parse(time.RFC3339, string(v))
+			if err != nil {
+				return err
+			}
+			dates[string(k)] = d
+			return nil
+		})
+	})
+
+	return dates, err
+}
+
+func setCompletedDate(kv *bolt.DB, xuid, titleID string, date time.Time) error {
+	return kv.Update(func(tx *bolt.Tx) error {
+		b, err := tx.Bucket([]byte("xboxlive_completed")).CreateBucketIfNotExists([]byte(xuid))
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(titleID), []byte(date.UTC().Format(time.RFC3339)))
+	})
+}
+
+// newlyUnlocked returns the titles in after with more achievements unlocked
+// than in before.
+func newlyUnlocked(before, after *XBLTitleHistory) (out []XBLTitle) {
+	out = []XBLTitle{}
+
+	was := map[string]int{}
+	for _, t := range before.Titles {
+		was[t.TitleID] = t.Achievement.CurrentAchievements
+	}
+
+	for _, t := range after.Titles {
+		if t.Achievement.CurrentAchievements > was[t.TitleID] {
+			out = append(out, t)
+		}
+	}
+
+	return out
+}
+
+// newlyCompleted returns the titles completed in after that weren't completed
+// in before.
+func newlyCompleted(before, after *XBLTitleHistory) (out []XBLTitle) {
+	out = []XBLTitle{}
+
+	was := map[string]bool{}
+	for _, t := range before.Titles {
+		was[t.TitleID] = t.Completed()
+	}
+
+	for _, t := range after.Titles {
+		if t.Completed() && !was[t.TitleID] {
+			out = append(out, t)
+		}
+	}
+
+	return out
+}
+
+// linkedPlayers maps the xuid of every linked player to the channels they've
+// been seen in and their nick.
+func linkedPlayers(kv *bolt.DB) (map[string]map[string]string, error) {
+	channels, err := getChannels(kv)
+	if err != nil {
+		return nil, err
+	}
+
+	players := map[string]map[string]string{}
+
+	for _, channel := range channels {
+		cp, err := channelPlayers(kv, channel)
+		if err != nil {
+			return nil, err
+		}
+
+		for xuid, nick := range cp {
+			if players[xuid] == nil {
+				players[xuid] = map[string]string{}
+			}
+			players[xuid][channel] = nick
+		}
+	}
+
+	return players, nil
+}
+
+// pollPlayers fetches the title history of every linked player, announcing
+// titles completed since the previous poll in each channel the player is in,
+// and recording achievements unlocked since then for the weekly recap. A
+// player that can't be polled is logged and skipped, leaving their snapshot
+// for the next poll.
+func pollPlayers(client *req.Client, kv *bolt.DB, announce announceFunc) error {
+	players, err := linkedPlayers(kv)
+	if err != nil {
+		return err
+	}
+
+	for xuid, channels := range players {
+		err = pollPlayer(client, kv, announce, xuid, channels)
+		if err != nil {
+			log.Printf("failed to poll %s: %s", xuid, err)
+		}
+	}
+
+	return nil
+}
+
+// pollPlayer polls one player, only storing their snapshot once what's new
+// in it has been recorded and announced.
+func pollPlayer(client *req.Client, kv *bolt.DB, announce announceFunc, xuid string, channels map[string]string) error {
+	history, err := xblGetTitleHistory(client, xuid)
+	if err != nil {
+		return err
+	}
+
+	before, err := getSnapshot(kv, xuid)
+	if err != nil {
+		return err
+	}
+
+	if before != nil {
+		for _, t := range newlyUnlocked(before, history) {
+			result, err := xblGetTitleAchievements(client, xuid, t.TitleID)
+			if err != nil {
+				return err
+			}
+
+			err = putUnlocks(kv, xuid, result.Achievements)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, t := range newlyCompleted(before, history) {
+			err = setCompletedDate(kv, xuid, t.TitleID, timeNow())
+			if err != nil {
+				return err
+			}
+
+			for channel, nick := range channels {
+				lang, err := channelLanguage(kv, channel)
+				if err != nil {
+					return err
+				}
+
+				announce(channel, render(lang, "completed.announce", NickTitle{Nick: nick, Title: t}))
+			}
+		}
+	}
+
+	err = putSnapshot(kv, xuid, history)
+	if err != nil {
+		return err
+	}
+
+	return cachePut(kv, "xboxlive_titlehistory", xuid, history)
+}
+
+func poll(client *req.Client, kv *bolt.DB, interval time.Duration, announce announceFunc, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			err := pollPlayers(client, kv, announce)
+			if err != nil {
+				log.Print(err)
+			}
+		}
+	}
+}
+
+// xblCompleted lists completed titles with the date they were completed.
+// Titles completed before polling noticed them are dated by their last
+// unlock, which is looked up once and stored. Only the undated titles that
+// could be shown are looked up, and one that can't be is left undated.
+func xblCompleted(client *req.Client, kv *bolt.DB, r *reply, gamerTag, xuid string) (string, error) {
+	history, err := cachedTitleHistory(client, kv, xuid)
+	if err != nil {
+		return "", err
+	}
+
+	dates, err := getCompletedDates(kv, xuid)
+	if err != nil {
+		return "", err
+	}
+
+	completed := []XBLTitle{}
+	for _, t := range history.Titles {
+		if t.Completed() {
+			completed = append(completed, t)
+		}
+	}
+
+	if len(completed) == 0 {
+		return r.render("completed.none", UserData{GamerTag: gamerTag}), nil
+	}
+
+	byDate := func(i, j int) bool {
+		return dates[completed[i].TitleID].After(dates[completed[j].TitleID])
+	}
+	sort.SliceStable(completed, byDate)
+
+	for _, t := range completed[:min(len(completed), completedLimit)] {
+		if _, ok := dates[t.TitleID]; ok {
+			continue
+		}
+
+		result, err := xblGetTitleAchievements(client, xuid, t.TitleID)
+		if err != nil {
+			log.Printf("failed to date %s for %s: %s", t.TitleID, xuid, err)
+			continue
+		}
+
+		timeline, err := result.Timeline(time.UTC)
+		if err != nil {
+			continue
+		}
+
+		dates[t.TitleID] = timeline.Last.Progression.TimeUnlocked
+		err = setCompletedDate(kv, xuid, t.TitleID, dates[t.TitleID])
+		if err != nil {
+			return "", err
+		}
+	}
+
+	sort.SliceStable(completed, byDate)
+
+	titles := []CompletedTitle{}
+	for _, t := range completed {
+		c := CompletedTitle{Title: t}
+		if d, ok := dates[t.TitleID]; ok {
+			d = d.In(r.Loc)
+			c.Date = &d
+		}
+		titles = append(titles, c)
+	}
+
+	return r.render("completed", CompletedData{GamerTag: gamerTag, Titles: titles, Limit: completedLimit}), nil
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/imroc/req/v3"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewlyCompleted(t *testing.T) {
+	cases := map[string]struct {
+		before   string
+		after    string
+		expected []string
+	}{
+		"nothing new": {
+			before:   "recent_titles.json",
+			after:    "recent_titles.json",
+			expected: []string{},
+		},
+		"already completed": {
+			before:   "completed_titles.json",
+			after:    "completed_titles.json",
+			expected: []string{},
+		},
+		"newly completed": {
+			before:   "recent_titles.json",
+			after:    "completed_titles.json",
+			expected: []string{"Lies of P"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			before := &XBLTitleHistory{}
+			err := json.Unmarshal(openTestFile(t, "XBLTitleHistory", tc.before), before)
+			assert.Nil(t, err)
+
+			after := &XBLTitleHistory{}
+			err = json.Unmarshal(openTestFile(t, "XBLTitleHistory", tc.after), after)
+			assert.Nil(t, err)
+
+			out := []string{}
+			for _, title := range newlyCompleted(before, after) {
+				out = append(out, title.Name)
+			}
+
+			assert.Equal(t, tc.expected, out)
+		})
+	}
+}
+
+func TestPollPlayers(t *testing.T) {
+	kv := openTestKV(t)
+
+	err := setUser(kv, []byte("dave"), []byte("test"), []byte("test"))
+	assert.Nil(t, err)
+	err = addChannelNick(kv, []byte("#gowon"), []byte("dave"))
+	assert.Nil(t, err)
+
+	now := time.Date(2024, 2, 5, 0, 0, 0, 0, time.UTC)
+	setTimeNow(t, now)
+
+	polls := []string{"recent_titles.json", "recent_titles.json", "completed_titles.json", "completed_titles.json"}
+
+	client := req.C()
+	httpmock.ActivateNonDefault(client.GetClient())
+	httpmock.RegisterResponder("GET", "https://xbl.io/api/v2/player/titleHistory/test", func(request *http.Request) (*http.Response, error) {
+		fn := polls[0]
+		polls = polls[1:]
+		resp := httpmock.NewBytesResponse(http.StatusOK, openTestFile(t, "XBLTitleHistory", fn))
+		return resp, nil
+	})
+	httpmock.RegisterResponder("GET", "https://xbl.io/api/v2/achievements/player/test/2071061510", func(request *http.Request) (*http.Response, error) {
+		resp := httpmock.NewBytesResponse(http.StatusOK, openTestFile(t, "XBLPlayerTitleAchievements", "completed.json"))
+		return resp, nil
+	})
+
+	announced := []string{}
+	announce := func(dest, msg string) {
+		announced = append(announced, dest+" "+msg)
+	}
+
+	for i := 0; i < 4; i++ {
+		err = pollPlayers(client, kv, announce)
+		assert.Nil(t, err)
+	}
+
+	assert.Equal(t, []string{"#gowon dave just completed {cyan}Lies of P{clear}! ({yellow}1,000/1,000{clear})"}, announced)
+
+	dates, err := getCompletedDates(kv, "test")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]time.Time{"2071061510": now}, dates)
+}
+
+func TestPollPlayersErrorStatus(t *testing.T) {
+	kv := openTestKV(t)
+
+	for nick, xuid := range map[string]string{"dave": "test", "sam": "broken"} {
+		err := setUser(kv, []byte(nick), []byte(nick), []byte(xuid))
+		assert.Nil(t, err)
+		err = addChannelNick(kv, []byte("#gowon"), []byte(nick))
+		assert.Nil(t, err)
+	}
+
+	completed := time.Date(2024, 2, 5, 0, 0, 0, 0, time.UTC)
+
+	polls := []string{"recent_titles.json", "completed_titles.json", "", "completed_titles.json"}
+
+	client := req.C()
+	httpmock.ActivateNonDefault(client.GetClient())
+	httpmock.RegisterResponder("GET", "https://xbl.io/api/v2/player/titleHistory/test", func(request *http.Request) (*http.Response, error) {
+		fn := polls[0]
+		polls = polls[1:]
+		if fn == "" {
+			return httpmock.NewStringResponse(http.StatusTooManyRequests, ""), nil
+		}
+		resp := httpmock.NewBytesResponse(http.StatusOK, openTestFile(t, "XBLTitleHistory", fn))
+		return resp, nil
+	})
+	httpmock.RegisterResponder("GET", "https://xbl.io/api/v2/player/titleHistory/broken", httpmock.NewStringResponder(http.StatusInternalServerError, ""))
+	httpmock.RegisterResponder("GET", "https://xbl.io/api/v2/achievements/player/test/2071061510", func(request *http.Request) (*http.Response, error) {
+		resp := httpmock.NewBytesResponse(http.StatusOK, openTestFile(t, "XBLPlayerTitleAchievements", "completed.json"))
+		return resp, nil
+	})
+
+	announced := []string{}
+	announce := func(dest, msg string) {
+		announced = append(announced, dest+" "+msg)
+	}
+
+	for i := 0; i < 4; i++ {
+		setTimeNow(t, completed.Add(time.Duration(i-1)*time.Hour))
+
+		err := pollPlayers(client, kv, announce)
+		assert.Nil(t, err)
+	}
+
+	assert.Equal(t, []string{"#gowon dave just completed {cyan}Lies of P{clear}! ({yellow}1,000/1,000{clear})"}, announced)
+
+	dates, err := getCompletedDates(kv, "test")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]time.Time{"2071061510": completed}, dates)
+
+	snapshot, err := getSnapshot(kv, "broken")
+	assert.Nil(t, err)
+	assert.Nil(t, snapshot)
+}
+
+func TestXblCompleted(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	assert.Nil(t, err)
+
+	cases := map[string]struct {
+		xblthfn  string
+		dates    map[string]time.Time
+		loc      *time.Location
+		expected string
+	}{
+		"nothing completed": {
+			xblthfn:  "recent_titles.json",
+			loc:      time.UTC,
+			expected: "test hasn't completed any xbox live games",
+		},
+		"date from last unlock": {
+			xblthfn:  "completed_titles.json",
+			loc:      time.UTC,
+			expected: "test's completed xbox live games: {green}Lies of P (2023-09-29){clear}",
+		},
+		"date from poll": {
+			xblthfn:  "completed_titles.json",
+			dates:    map[string]time.Time{"2071061510": time.Date(2024, 2, 4, 21, 0, 0, 0, time.UTC)},
+			loc:      time.UTC,
+			expected: "test's completed xbox live games: {green}Lies of P (2024-02-04){clear}",
+		},
+		"date in the reader's timezone": {
+			xblthfn:  "completed_titles.json",
+			dates:    map[string]time.Time{"2071061510": time.Date(2024, 2, 4, 21, 0, 0, 0, time.UTC)},
+			loc:      tokyo,
+			expected: "test's completed xbox live games: {green}Lies of P (2024-02-05){clear}",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			kv := openTestKV(t)
+
+			history := &XBLTitleHistory{}
+			err := json.Unmarshal(openTestFile(t, "XBLTitleHistory", tc.xblthfn), history)
+			assert.Nil(t, err)
+
+			err = cachePut(kv, "xboxlive_titlehistory", "test", history)
+			assert.Nil(t, err)
+
+			for id, d := range tc.dates {
+				err = setCompletedDate(kv, "test", id, d)
+				assert.Nil(t, err)
+			}
+
+			xblptajson := openTestFile(t, "XBLPlayerTitleAchievements", "completed.json")
+
+			client := req.C()
+			httpmock.ActivateNonDefault(client.GetClient())
+			httpmock.RegisterResponder("GET", "https://xbl.io/api/v2/achievements/player/test/2071061510", func(request *http.Request) (*http.Response, error) {
+				resp := httpmock.NewBytesResponse(http.StatusOK, xblptajson)
+				return resp, nil
+			})
+
+			out, err := xblCompleted(client, kv, newReply("en", tc.loc), "test", "test")
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, out)
+		})
+	}
+}
+
+func TestXblCompletedDatesShownTitles(t *testing.T) {
+	kv := openTestKV(t)
+
+	history := &XBLTitleHistory{}
+	err := json.Unmarshal(openTestFile(t, "XBLTitleHistory", "completed_titles.json"), history)
+	assert.Nil(t, err)
+
+	for i := range history.Titles {
+		history.Titles[i].Achievement.ProgressPercentage = 100
+	}
+
+	err = cachePut(kv, "xboxlive_titlehistory", "test", history)
+	assert.Nil(t, err)
+
+	xblptajson := openTestFile(t, "XBLPlayerTitleAchievements", "completed.json")
+
+	client := req.C()
+	httpmock.ActivateNonDefault(client.GetClient())
+	httpmock.RegisterResponder("GET", `=~^https://xbl\.io/api/v2/achievements/player/test/`, func(request *http.Request) (*http.Response, error) {
+		return httpmock.NewBytesResponse(http.StatusOK, xblptajson), nil
+	})
+	httpmock.RegisterResponder("GET", "https://xbl.io/api/v2/achievements/player/test/2043073184", httpmock.NewStringResponder(http.StatusInternalServerError, ""))
+	httpmock.ZeroCallCounters()
+
+	out, err := xblCompleted(client, kv, testReply(), "test", "test")
+	assert.Nil(t, err)
+	assert.Contains(t, out, "Halo Infinite{clear}, +2 more")
+
+	calls := httpmock.GetCallCountInfo()
+	assert.Equal(t, completedLimit, httpmock.GetTotalCallCount())
+	assert.Equal(t, 0, calls["GET https://xbl.io/api/v2/achievements/player/test/1297287125"])
+	assert.Equal(t, 0, calls["GET https://xbl.io/api/v2/achievements/player/test/1297287142"])
+}
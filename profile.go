@@ -0,0 +1,110 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/imroc/req/v3"
+)
+
+// XBLProfile is the structured form of a player's profile card, published for
+// front ends that can render more than a line of irc text.
+type XBLProfile struct {
+	Xuid           string `json:"xuid"`
+	Gamertag       string `json:"gamertag"`
+	UniqueGamertag string `json:"uniqueGamertag"`
+	Gamerscore     int    `json:"gamerscore"`
+	Reputation     string `json:"reputation"`
+	AccountTier    string `json:"accountTier"`
+	Bio            string `json:"bio,omitempty"`
+	Location       string `json:"location,omitempty"`
+	Tenure         string `json:"tenure,omitempty"`
+	Verified       bool   `json:"verified"`
+	Followers      int    `json:"followers"`
+	Following      int    `json:"following"`
+	GamePass       bool   `json:"gamePass"`
+	DisplayPic     string `json:"displayPic"`
+	Colour         string `json:"colour"`
+}
+
+func (p XBLPlayer) Profile() XBLProfile {
+	gamerscore, _ := strconv.Atoi(p.GamerScore)
+
+	return XBLProfile{
+		Xuid:           p.Xuid,
+		Gamertag:       p.Gamertag,
+		UniqueGamertag: p.UniqueModernGamertag,
+		Gamerscore:     gamerscore,
+		Reputation:     p.XboxOneRep,
+		AccountTier:    p.Detail.AccountTier,
+		Bio:            p.Detail.Bio,
+		Location:       p.Detail.Location,
+		Tenure:         p.Detail.Tenure,
+		Verified:       p.Detail.IsVerified,
+		Followers:      p.Detail.FollowerCount,
+		Following:      p.Detail.FollowingCount,
+		GamePass:       p.Detail.HasGamePass,
+		DisplayPic:     p.DisplayPicRaw,
+		Colour:         p.PreferredColor.PrimaryColor,
+	}
+}
+
+// repString splits a reputation like GoodPlayer into words.
+func repString(rep string) string {
+	var sb strings.Builder
+
+	for i, r := range rep {
+		if i > 0 && unicode.IsUpper(r) {
+			sb.WriteRune(' ')
+		}
+		sb.WriteRune(r)
+	}
+
+	return sb.String()
+}
+
+func repColour(rep string) string {
+	switch rep {
+	case "GoodPlayer":
+		return "green"
+	case "NeedsWork":
+		return "yellow"
+	}
+	return "red"
+}
+
+// TenureYears is how many years the player has been on xbox live, or 0 when
+// it's not shared.
+func (p XBLPlayer) TenureYears() int {
+	years, _ := strconv.Atoi(p.Detail.Tenure)
+	return years
+}
+
+// BioLine is the player's bio collapsed onto one line.
+func (p XBLPlayer) BioLine() string {
+	return strings.Join(strings.Fields(p.Detail.Bio), " ")
+}
+
+// xblGetProfile searches for gamerTag and returns the result with a matching
+// xuid, as the search endpoint is the only one that includes profile details.
+func xblGetProfile(client *req.Client, gamerTag, xuid string) (*XBLPlayer, error) {
+	result := &XBLXuidSearch{}
+
+	_, err := client.R().
+		SetPathParam("user", gamerTag).
+		SetSuccessResult(&result).
+		Get("https://xbl.io/api/v2/search/{user}")
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range result.People {
+		if p.Xuid == xuid {
+			return &p, nil
+		}
+	}
+
+	return nil, userNotFoundErr
+}
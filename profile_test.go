@@ -0,0 +1,93 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/imroc/req/v3"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepString(t *testing.T) {
+	cases := map[string]struct {
+		in       string
+		expected string
+	}{
+		"empty":       {in: "", expected: ""},
+		"one word":    {in: "Good", expected: "Good"},
+		"two words":   {in: "GoodPlayer", expected: "Good Player"},
+		"three words": {in: "AvoidMeNow", expected: "Avoid Me Now"},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, repString(tc.in))
+		})
+	}
+}
+
+func TestXblGetProfile(t *testing.T) {
+	cases := map[string]struct {
+		xblxs   string
+		xuid    string
+		card    string
+		profile XBLProfile
+		err     error
+	}{
+		"first result": {
+			xblxs:   "user_exists.json",
+			xuid:    "2533274798129181",
+			card:    "{cyan}xTACTICSx{clear} | {yellow}19,165{clear} | {green}Good Player{clear} | Gold | 41 followers, 0 following",
+			profile: XBLProfile{Xuid: "2533274798129181", Gamertag: "xTACTICSx", UniqueGamertag: "xTACTICSx", Gamerscore: 19165, Reputation: "GoodPlayer", AccountTier: "Gold", Followers: 41, Colour: "193e91"},
+		},
+		"suffixed gamertag": {
+			xblxs:   "user_exists.json",
+			xuid:    "2533274891591060",
+			card:    "{cyan}xTACTICSx{clear}#6152 | {yellow}50,316{clear} | {green}Good Player{clear} | Gold | 156 followers, 6 following",
+			profile: XBLProfile{Xuid: "2533274891591060", Gamertag: "xEVIL TACTICSx", UniqueGamertag: "xTACTICSx#6152", Gamerscore: 50316, Reputation: "GoodPlayer", AccountTier: "Gold", Followers: 156, Following: 6, Colour: "108272"},
+		},
+		"full profile": {
+			xblxs:   "profile.json",
+			xuid:    "2533274812012273",
+			card:    "{cyan}player{clear} (verified) | {yellow}3,225{clear} | {yellow}Needs Work{clear} | Gold | 12 years | 1 follower, 25 following | Leeds | Soulslike enjoyer. Send invites",
+			profile: XBLProfile{Xuid: "2533274812012273", Gamertag: "player", UniqueGamertag: "player", Gamerscore: 3225, Reputation: "NeedsWork", AccountTier: "Gold", Bio: "Soulslike enjoyer.\n  Send invites", Location: "Leeds", Tenure: "12", Verified: true, Followers: 1, Following: 25, GamePass: true, Colour: "107c10"},
+		},
+		"not in results": {
+			xblxs: "user_exists.json",
+			xuid:  "1",
+			err:   userNotFoundErr,
+		},
+		"no results": {
+			xblxs: "user_doesnt_exist.json",
+			xuid:  "2533274798129181",
+			err:   userNotFoundErr,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			xblxsjson := openTestFile(t, "XBLXuidSearch", tc.xblxs)
+
+			client := req.C()
+			httpmock.ActivateNonDefault(client.GetClient())
+			httpmock.RegisterResponder("GET", "https://xbl.io/api/v2/search/test", func(request *http.Request) (*http.Response, error) {
+				resp := httpmock.NewBytesResponse(http.StatusOK, xblxsjson)
+				return resp, nil
+			})
+
+			p, err := xblGetProfile(client, "test", tc.xuid)
+			assert.ErrorIs(t, err, tc.err)
+
+			if tc.err != nil {
+				return
+			}
+
+			assert.Equal(t, tc.card, testReply().render("profile.card", p))
+
+			profile := p.Profile()
+			profile.DisplayPic = ""
+			assert.Equal(t, tc.profile, profile)
+		})
+	}
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/robfig/cron/v3"
+)
+
+const (
+	recapWindow     = time.Hour * 24 * 7
+	recapLimit      = 3
+	unlockRetention = time.Hour * 24 * 35
+	unlockKeyFormat = "2006-01-02T15:04:05.000000000Z"
+)
+
+type unlockRecord struct {
+	TitleName string    `json:"titleName"`
+	Name      string    `json:"name"`
+	Rarity    float64   `json:"rarity"`
+	Unlocked  time.Time `json:"unlocked"`
+}
+
+// putUnlocks records the unlocked achievements newer than unlockRetention,
+// keyed by unlock time so they can be read back in order. Records older than
+// unlockRetention are removed.
+func putUnlocks(kv *bolt.DB, xuid string, achievements []XBLAchievement) error {
+	cutoff := timeNow().Add(-unlockRetention)
+
+	return kv.Update(func(tx *bolt.Tx) error {
+		b, err := tx.Bucket([]byte("xboxlive_unlocks")).CreateBucketIfNotExists([]byte(xuid))
+		if err != nil {
+			return err
+		}
+
+		for _, a := range achievements {
+			unlocked := a.Progression.TimeUnlocked
+			if !a.Unlocked() || unlocked.Before(cutoff) {
+				continue
+			}
+
+			v, err := json.Marshal(unlockRecord{
+				TitleName: a.TitleName(),
+				Name:      a.Name,
+				Rarity:    a.Rarity.CurrentPercentage,
+				Unlocked:  unlocked,
+			})
+			if err != nil {
+				return err
+			}
+
+			k := fmt.Sprintf("%s/%s/%s", unlocked.UTC().Format(unlockKeyFormat), a.TitleName(), a.ID)
+			err = b.Put([]byte(k), v)
+			if err != nil {
+				return err
+			}
+		}
+
+		c := b.Cursor()
+		for k, _ := c.First(); k != nil && string(k) < cutoff.UTC().Format(unlockKeyFormat); k, _ = c.Next() {
+			err = c.Delete()
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func getUnlocks(kv *bolt.DB, xuid string, since time.Time) (unlocks []unlockRecord, err error) {
+	unlocks = []unlockRecord{}
+
+	err = kv.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("xboxlive_unlocks")).Bucket([]byte(xuid))
+		if b == nil {
+			return nil
+		}
+
+		c := b.Cursor()
+		for k, v := c.Seek([]byte(since.UTC().Format(unlockKeyFormat))); k != nil; k, v = c.Next() {
+			u := unlockRecord{}
+			if err := json.Unmarshal(v, &u); err != nil {
+				return err
+			}
+			unlocks = append(unlocks, u)
+		}
+
+		return nil
+	})
+
+	return unlocks, err
+}
+
+type recapPlayer struct {
+	Nick    string
+	Before  *XBLTitleHistory
+	After   *XBLTitleHistory
+	Unlocks []unlockRecord
+}
+
+func (xblth *XBLTitleHistory) Gamerscore() (score int) {
+	for _, t := range xblth.Titles {
+		score += t.Achievement.CurrentGamerscore
+	}
+
+	return score
+}
+
+// buildRecap summarises what players did since: gamerscore gained, games
+// played by the most players, the rarest unlock and games started.
+func buildRecap(r *reply, channel string, players []recapPlayer, since time.Time) string {
+	data := RecapData{Channel: channel, Gains: []RecapGain{}, Played: []RecapPlayed{}, Started: []NickTitle{}, Limit: recapLimit}
+
+	sort.SliceStable(players, func(i, j int) bool {
+		return players[i].Nick < players[j].Nick
+	})
+
+	gains := []recapPlayer{}
+	for _, p := range players {
+		if p.After.Gamerscore() > p.Before.Gamerscore() {
+			gains = append(gains, p)
+		}
+	}
+
+	sort.SliceStable(gains, func(i, j int) bool {
+		return gains[i].After.Gamerscore()-gains[i].Before.Gamerscore() > gains[j].After.Gamerscore()-gains[j].Before.Gamerscore()
+	})
+
+	for _, p := range gains[:min(recapLimit, len(gains))] {
+		data.Gains = append(data.Gains, RecapGain{Nick: p.Nick, Gain: p.After.Gamerscore() - p.Before.Gamerscore()})
+	}
+
+	played := map[string]int{}
+	names := map[string]string{}
+	for _, p := range players {
+		for _, t := range p.After.Titles {
+			if t.TitleHistory.LastTimePlayed.After(since) {
+				played[t.TitleID]++
+				names[t.TitleID] = t.Name
+			}
+		}
+	}
+
+	if len(played) > 0 {
+		ids := []string{}
+		for id := range played {
+			ids = append(ids, id)
+		}
+
+		sort.Slice(ids, func(i, j int) bool {
+			if played[ids[i]] != played[ids[j]] {
+				return played[ids[i]] > played[ids[j]]
+			}
+			return names[ids[i]] < names[ids[j]]
+		})
+
+		for _, id := range ids[:min(recapLimit, len(ids))] {
+			data.Played = append(data.Played, RecapPlayed{TitleID: id, Name: names[id], Players: played[id]})
+		}
+	}
+
+	for _, p := range players {
+		for _, u := range p.Unlocks {
+			if u.Unlocked.After(since) && (data.Rarest == nil || u.Rarity < data.Rarest.Unlock.Rarity) {
+				data.Rarest = &RecapUnlock{Nick: p.Nick, Unlock: u}
+			}
+		}
+	}
+
+	for _, p := range players {
+		had := map[string]bool{}
+		for _, t := range p.Before.Titles {
+			had[t.TitleID] = true
+		}
+
+		for _, t := range p.After.Titles {
+			if !had[t.TitleID] {
+				data.Started = append(data.Started, NickTitle{Nick: p.Nick, Title: t})
+			}
+		}
+	}
+
+	if len(data.Gains) == 0 && len(data.Played) == 0 && data.Rarest == nil && len(data.Started) == 0 {
+		return r.render("recap.none", RecapData{Channel: channel})
+	}
+
+	return r.render("recap", data)
+}
+
+// recapFor builds the recap for channel from the snapshots and unlocks stored
+// by polling, without calling the api.
+func recapFor(kv *bolt.DB, r *reply, channel string) (string, error) {
+	players, err := channelPlayers(kv, channel)
+	if err != nil {
+		return "", err
+	}
+
+	since := timeNow().Add(-recapWindow)
+	recap := []recapPlayer{}
+
+	for xuid, nick := range players {
+		after, err := getSnapshot(kv, xuid)
+		if err != nil {
+			return "", err
+		}
+
+		if after == nil {
+			continue
+		}
+
+		before, err := getSnapshotAt(kv, xuid, since)
+		if err != nil {
+			return "", err
+		}
+
+		unlocks, err := getUnlocks(kv, xuid, since)
+		if err != nil {
+			return "", err
+		}
+
+		recap = append(recap, recapPlayer{Nick: nick, Before: before, After: after, Unlocks: unlocks})
+	}
+
+	return buildRecap(r, channel, recap, since), nil
+}
+
+// postRecaps announces the recap in every channel where something happened
+// this week. Quiet channels, and dests without linked players such as
+// private messages, are skipped rather than told nothing happened.
+func postRecaps(kv *bolt.DB, announce announceFunc) error {
+	channels, err := getChannels(kv)
+	if err != nil {
+		return err
+	}
+
+	for _, channel := range channels {
+		lang, err := channelLanguage(kv, channel)
+		if err != nil {
+			log.Print(err)
+			continue
+		}
+
+		r := newReply(lang, time.UTC)
+
+		recap, err := recapFor(kv, r, channel)
+		if err != nil {
+			log.Print(err)
+			continue
+		}
+
+		if r.Template != "recap" {
+			continue
+		}
+
+		announce(channel, recap)
+	}
+
+	return nil
+}
+
+func scheduleRecap(kv *bolt.DB, spec string, announce announceFunc) (*cron.Cron, error) {
+	c := cron.New()
+
+	_, err := c.AddFunc(spec, func() {
+		err := postRecaps(kv, announce)
+		if err != nil {
+			log.Print(err)
+		}
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	c.Start()
+
+	return c, nil
+}
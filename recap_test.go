@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPutUnlocks(t *testing.T) {
+	kv := openTestKV(t)
+
+	result := &XBLPlayerTitleAchievements{}
+	err := json.Unmarshal(openTestFile(t, "XBLPlayerTitleAchievements", "completed.json"), result)
+	assert.Nil(t, err)
+
+	setTimeNow(t, time.Date(2023, 10, 1, 0, 0, 0, 0, time.UTC))
+
+	for i := 0; i < 2; i++ {
+		err = putUnlocks(kv, "test", result.Achievements)
+		assert.Nil(t, err)
+	}
+
+	unlocks, err := getUnlocks(kv, "test", time.Date(2023, 9, 22, 22, 0, 0, 0, time.UTC))
+	assert.Nil(t, err)
+
+	names := []string{}
+	for _, u := range unlocks {
+		names = append(names, u.TitleName+" - "+u.Name)
+	}
+	assert.Equal(t, []string{"Cocoon - Eggcellent", "Cocoon - The End"}, names)
+
+	setTimeNow(t, time.Date(2023, 10, 30, 0, 0, 0, 0, time.UTC))
+
+	err = putUnlocks(kv, "test", nil)
+	assert.Nil(t, err)
+
+	unlocks, err = getUnlocks(kv, "test", time.Time{})
+	assert.Nil(t, err)
+	assert.Len(t, unlocks, 1)
+}
+
+func TestBuildRecap(t *testing.T) {
+	cases := map[string]struct {
+		before   map[string]string
+		after    map[string]string
+		unlocks  map[string][]unlockRecord
+		since    time.Time
+		expected string
+	}{
+		"quiet week": {
+			before:   map[string]string{"dave": "completed_titles.json"},
+			after:    map[string]string{"dave": "completed_titles.json"},
+			since:    time.Date(2024, 2, 5, 0, 0, 0, 0, time.UTC),
+			expected: "nothing happened on xbox live in #gowon this week",
+		},
+		"busy week": {
+			before: map[string]string{"dave": "recent_titles.json", "bob": "recent_titles.json"},
+			after:  map[string]string{"dave": "completed_titles.json", "bob": "recent_titles.json"},
+			unlocks: map[string][]unlockRecord{
+				"dave": {
+					{TitleName: "Lies of P", Name: "Unbroken", Rarity: 12.5, Unlocked: time.Date(2024, 2, 3, 20, 0, 0, 0, time.UTC)},
+					{TitleName: "Lies of P", Name: "Lies of P", Rarity: 3.2, Unlocked: time.Date(2024, 2, 4, 20, 0, 0, 0, time.UTC)},
+				},
+				"bob": {
+					{TitleName: "Persona 3 Reload", Name: "Old News", Rarity: 1.1, Unlocked: time.Date(2024, 1, 20, 20, 0, 0, 0, time.UTC)},
+				},
+			},
+			since: time.Date(2024, 1, 29, 0, 0, 0, 0, time.UTC),
+			expected: "weekly xbox live recap for #gowon\n" +
+				"top gamerscore gains: {green}dave +495{clear}\n" +
+				"most played: {green}Persona 3 Reload (2){clear}, {red}Lies of P (1){clear}\n" +
+				"rarest unlock: dave - Lies of P - Lies of P {yellow}3.2%{clear}",
+		},
+		"rarest unlock first": {
+			before: map[string]string{"dave": "completed_titles.json"},
+			after:  map[string]string{"dave": "completed_titles.json"},
+			unlocks: map[string][]unlockRecord{
+				"dave": {
+					{TitleName: "Lies of P", Name: "Lies of P", Rarity: 3.2, Unlocked: time.Date(2024, 2, 7, 20, 0, 0, 0, time.UTC)},
+					{TitleName: "Lies of P", Name: "Unbroken", Rarity: 12.5, Unlocked: time.Date(2024, 2, 6, 20, 0, 0, 0, time.UTC)},
+				},
+			},
+			since: time.Date(2024, 2, 5, 0, 0, 0, 0, time.UTC),
+			expected: "weekly xbox live recap for #gowon\n" +
+				"rarest unlock: dave - Lies of P - Lies of P {yellow}3.2%{clear}",
+		},
+		"new games": {
+			before: map[string]string{"dave": "no_titles.json"},
+			after:  map[string]string{"dave": "recent_titles.json"},
+			since:  time.Date(2024, 2, 5, 0, 0, 0, 0, time.UTC),
+			expected: "weekly xbox live recap for #gowon\n" +
+				"top gamerscore gains: {green}dave +3,210{clear}\n" +
+				"new games: dave started {cyan}Persona 3 Reload{clear}, dave started {cyan}Lies of P{clear}, dave started {cyan}Wo Long: Fallen Dynasty{clear}, +9 more",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			players := []recapPlayer{}
+
+			for nick, fn := range tc.after {
+				before := &XBLTitleHistory{}
+				err := json.Unmarshal(openTestFile(t, "XBLTitleHistory", tc.before[nick]), before)
+				assert.Nil(t, err)
+
+				after := &XBLTitleHistory{}
+				err = json.Unmarshal(openTestFile(t, "XBLTitleHistory", fn), after)
+				assert.Nil(t, err)
+
+				players = append(players, recapPlayer{Nick: nick, Before: before, After: after, Unlocks: tc.unlocks[nick]})
+			}
+
+			out := buildRecap(testReply(), "#gowon", players, tc.since)
+			assert.Equal(t, tc.expected, out)
+		})
+	}
+}
+
+func TestPostRecaps(t *testing.T) {
+	kv := openTestKV(t)
+
+	err := setUser(kv, []byte("dave"), []byte("dave"), []byte("test"))
+	assert.Nil(t, err)
+	err = setUser(kv, []byte("bob"), []byte("bob"), []byte("idle"))
+	assert.Nil(t, err)
+
+	for channel, nick := range map[string]string{"#gowon": "dave", "#quiet": "bob", "#help": "sam", "sam": "sam"} {
+		err = addChannelNick(kv, []byte(channel), []byte(nick))
+		assert.Nil(t, err)
+	}
+
+	for day, fn := range map[int]string{1: "recent_titles.json", 5: "completed_titles.json"} {
+		setTimeNow(t, time.Date(2024, 2, day, 0, 0, 0, 0, time.UTC))
+
+		for xuid, f := range map[string]string{"test": fn, "idle": "recent_titles.json"} {
+			history := &XBLTitleHistory{}
+			err = json.Unmarshal(openTestFile(t, "XBLTitleHistory", f), history)
+			assert.Nil(t, err)
+
+			err = putSnapshot(kv, xuid, history)
+			assert.Nil(t, err)
+		}
+	}
+
+	setTimeNow(t, time.Date(2024, 2, 11, 0, 0, 0, 0, time.UTC))
+
+	dests, msgs := []string{}, []string{}
+	err = postRecaps(kv, func(dest, msg string) {
+		dests = append(dests, dest)
+		msgs = append(msgs, msg)
+	})
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"#gowon"}, dests)
+	assert.Contains(t, msgs[0], "top gamerscore gains: {green}dave +495{clear}")
+}
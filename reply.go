@@ -0,0 +1,252 @@
+package main
+
+import (
+	"time"
+
+	"github.com/gowon-irc/go-gowon"
+)
+
+// replyVersion is bumped whenever XBLReply or one of the data types below
+// changes in a way that breaks consumers.
+const replyVersion = 1
+
+// reply is how a command renders its reply: the language and timezone of
+// whoever asked, and the template and data the reply was last rendered from,
+// which are published as json alongside the irc text.
+type reply struct {
+	Lang     string
+	Loc      *time.Location
+	Template string
+	Data     any
+	// Lookup is how the user the reply is about was found, when they were
+	// named rather than being whoever asked.
+	Lookup *XBLLookup
+}
+
+func newReply(lang string, loc *time.Location) *reply {
+	return &reply{Lang: lang, Loc: loc}
+}
+
+// render renders the reply to a command and remembers it. Templates nested
+// within a reply, such as each friend in a list, use render directly.
+func (r *reply) render(name string, data any) string {
+	r.Template, r.Data = name, data
+	return render(r.Lang, name, data)
+}
+
+// XBLReply is the json document published to the reply topic for every
+// command, so other front ends don't need to parse irc text.
+type XBLReply struct {
+	Version int        `json:"version"`
+	Module  string     `json:"module"`
+	Request XBLRequest `json:"request"`
+	// Reply names the template the reply was rendered from, such as recent
+	// or error.no_user.
+	Reply string `json:"reply"`
+	// Text is the reply without any colours.
+	Text string `json:"text"`
+	// Data is one of the *Data types below for replies about players,
+	// titles, achievements, captures or game pass. Errors, help and
+	// settings replies carry an object of the fields their template uses,
+	// or nothing.
+	Data any `json:"data,omitempty"`
+	// Lookup says how a user named in the request was found.
+	Lookup *XBLLookup `json:"lookup,omitempty"`
+}
+
+// XBLRequest identifies the message a reply answers.
+type XBLRequest struct {
+	ID   string `json:"id,omitempty"`
+	Nick string `json:"nick"`
+	Dest string `json:"dest"`
+	Msg  string `json:"msg"`
+}
+
+// PlayerData is the data for player and profile replies.
+type PlayerData struct {
+	GamerTag string    `json:"gamertag"`
+	Player   XBLPlayer `json:"player"`
+}
+
+// TitleData is the data for game replies.
+type TitleData struct {
+	GamerTag string   `json:"gamertag"`
+	Title    XBLTitle `json:"title"`
+}
+
+// LastGameData is the data for last replies.
+type LastGameData struct {
+	TitleData
+	Played time.Time `json:"played"`
+}
+
+// TitlesData is the data for recent replies. Earned holds the gamerscore
+// earned per title id when ordered by score.
+type TitlesData struct {
+	GamerTag string         `json:"gamertag"`
+	Titles   []XBLTitle     `json:"titles"`
+	Order    string         `json:"order"`
+	Earned   map[string]int `json:"earned,omitempty"`
+}
+
+// AchievementData is the data for chase replies.
+type AchievementData struct {
+	GamerTag    string         `json:"gamertag"`
+	Achievement XBLAchievement `json:"achievement"`
+}
+
+// LastAchievementData is the data for achievement replies.
+type LastAchievementData struct {
+	AchievementData
+	Unlocked time.Time `json:"unlocked"`
+}
+
+// AchievementsData is the data for rare and next replies.
+type AchievementsData struct {
+	GamerTag     string           `json:"gamertag"`
+	Achievements []XBLAchievement `json:"achievements"`
+}
+
+// UserData is the data for replies that only name the player, such as when
+// they've nothing to list.
+type UserData struct {
+	GamerTag string `json:"gamertag"`
+}
+
+// NickPlayer is a linked player and their presence.
+type NickPlayer struct {
+	Nick   string    `json:"nick"`
+	Player XBLPlayer `json:"player"`
+}
+
+// NickTitle is a linked player and their progress in a title.
+type NickTitle struct {
+	Nick  string   `json:"nick"`
+	Title XBLTitle `json:"title"`
+}
+
+// FriendsData is the data for friends replies.
+type FriendsData struct {
+	GamerTag string      `json:"gamertag"`
+	Online   int         `json:"online"`
+	Friends  []XBLPlayer `json:"friends"`
+	Limit    int         `json:"-"`
+}
+
+// GameGroup is the linked players playing the same thing.
+type GameGroup struct {
+	Name    string       `json:"name"`
+	Players []NickPlayer `json:"players"`
+}
+
+// PlayingData is the data for online and party replies.
+type PlayingData struct {
+	Games []GameGroup `json:"games"`
+}
+
+// CommonData is the data for common replies.
+type CommonData struct {
+	Nicks  []string   `json:"nicks"`
+	Titles []XBLTitle `json:"titles"`
+	Limit  int        `json:"-"`
+}
+
+// WhoPlaysData is the data for whoplays replies.
+type WhoPlaysData struct {
+	Title   XBLTitle    `json:"title"`
+	Players []NickTitle `json:"players"`
+}
+
+// CapturesData is the data for clips and shots replies.
+type CapturesData struct {
+	GamerTag string       `json:"gamertag"`
+	Kind     string       `json:"kind"`
+	Captures []XBLCapture `json:"captures"`
+}
+
+// TimelineData is the data for timeline replies. Timeline is missing when
+// nothing in the title has been unlocked.
+type TimelineData struct {
+	TitleData
+	Timeline *XBLTimeline `json:"timeline,omitempty"`
+}
+
+// CompletedTitle is a completed title and when it was completed, if known.
+type CompletedTitle struct {
+	Title XBLTitle   `json:"title"`
+	Date  *time.Time `json:"date,omitempty"`
+}
+
+// CompletedData is the data for completed replies.
+type CompletedData struct {
+	GamerTag string           `json:"gamertag"`
+	Titles   []CompletedTitle `json:"titles"`
+	Limit    int              `json:"-"`
+}
+
+// RecapGain is the gamerscore a player gained over the week.
+type RecapGain struct {
+	Nick string `json:"nick"`
+	Gain int    `json:"gain"`
+}
+
+// RecapPlayed is how many players played a title over the week.
+type RecapPlayed struct {
+	TitleID string `json:"titleId"`
+	Name    string `json:"name"`
+	Players int    `json:"players"`
+}
+
+// RecapUnlock is the rarest achievement unlocked over the week.
+type RecapUnlock struct {
+	Nick   string       `json:"nick"`
+	Unlock unlockRecord `json:"unlock"`
+}
+
+// RecapData is the data for recap replies.
+type RecapData struct {
+	Channel string        `json:"channel"`
+	Gains   []RecapGain   `json:"gains"`
+	Played  []RecapPlayed `json:"played"`
+	Rarest  *RecapUnlock  `json:"rarest,omitempty"`
+	Started []NickTitle   `json:"started"`
+	Limit   int           `json:"-"`
+}
+
+// GamePassData is the data for gamepass replies about one game.
+type GamePassData struct {
+	Product GamePassProduct `json:"product"`
+	Added   bool            `json:"added"`
+	Leaving bool            `json:"leaving"`
+}
+
+// GamePassListData is the data for gamepass new and leaving replies.
+type GamePassListData struct {
+	Products []GamePassProduct `json:"products"`
+	Limit    int               `json:"-"`
+}
+
+// InfoData is the data for info replies.
+type InfoData struct {
+	Info     XBLTitleInfo `json:"info"`
+	GamePass bool         `json:"gamePass"`
+}
+
+// newXBLReply builds the document for the reply r rendered as out in answer
+// to ms.
+func newXBLReply(ms gowon.Message, r *reply, out string) XBLReply {
+	return XBLReply{
+		Version: replyVersion,
+		Module:  moduleName,
+		Request: XBLRequest{
+			ID:   ms.Tags["msgid"],
+			Nick: ms.Nick,
+			Dest: ms.Dest,
+			Msg:  ms.Msg,
+		},
+		Reply:  r.Template,
+		Text:   renderOutput(outputPlain, out),
+		Data:   r.Data,
+		Lookup: r.Lookup,
+	}
+}
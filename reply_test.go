@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gowon-irc/go-gowon"
+	"github.com/imroc/req/v3"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// testReply renders replies in english with times in utc.
+func testReply() *reply {
+	return newReply("en", time.UTC)
+}
+
+func TestReplyRender(t *testing.T) {
+	xblpsjson := openTestFile(t, "XBLPlayerSummary", "friends.json")
+
+	client := req.C()
+	httpmock.ActivateNonDefault(client.GetClient())
+	httpmock.RegisterResponder("GET", "https://xbl.io/api/v2/friends?xuid=test", func(request *http.Request) (*http.Response, error) {
+		return httpmock.NewBytesResponse(http.StatusOK, xblpsjson), nil
+	})
+
+	r := testReply()
+	out, err := xblFriends(client, r, "test", "test")
+	assert.Nil(t, err)
+	assert.Equal(t, "friends", r.Template)
+
+	data, ok := r.Data.(FriendsData)
+	assert.True(t, ok)
+	assert.Equal(t, "test", data.GamerTag)
+	assert.NotEmpty(t, data.Friends)
+
+	b, err := json.Marshal(newXBLReply(gowon.Message{Nick: "dave", Dest: "#gowon"}, r, out))
+	assert.Nil(t, err)
+	assert.NotContains(t, string(b), "{clear}")
+	assert.Contains(t, string(b), `"friends":[{"xuid":`)
+}
+
+func TestNewXBLReply(t *testing.T) {
+	ms := gowon.Message{
+		Nick: "dave",
+		Dest: "#gowon",
+		Msg:  ".xbl game halo",
+		Tags: map[string]string{"msgid": "abc123"},
+	}
+
+	title := XBLTitle{Name: "Halo Infinite", TitleID: "2043073184"}
+	title.Achievement.CurrentGamerscore = 100
+	title.Achievement.TotalGamerscore = 1000
+
+	r := testReply()
+	out := r.render("game", TitleData{GamerTag: "test", Title: title})
+
+	b, err := json.Marshal(newXBLReply(ms, r, out))
+	assert.Nil(t, err)
+
+	doc := map[string]any{}
+	err = json.Unmarshal(b, &doc)
+	assert.Nil(t, err)
+
+	assert.Equal(t, float64(replyVersion), doc["version"])
+	assert.Equal(t, moduleName, doc["module"])
+	assert.Equal(t, "game", doc["reply"])
+	assert.Equal(t, map[string]any{"id": "abc123", "nick": "dave", "dest": "#gowon", "msg": ".xbl game halo"}, doc["request"])
+	assert.NotContains(t, doc["text"], "{")
+
+	data := doc["data"].(map[string]any)
+	assert.Equal(t, "test", data["gamertag"])
+	assert.Equal(t, "Halo Infinite", data["title"].(map[string]any)["name"])
+}
+
+func TestNewXBLReplyNoData(t *testing.T) {
+	r := testReply()
+	out := r.render("error.unterminated_quote", nil)
+
+	b, err := json.Marshal(newXBLReply(gowon.Message{Nick: "dave", Dest: "#gowon"}, r, out))
+	assert.Nil(t, err)
+	assert.NotContains(t, string(b), `"data"`)
+	assert.NotContains(t, string(b), `"id"`)
+	assert.NotContains(t, string(b), `"lookup"`)
+}
+
+func TestNewXBLReplyCapturesArePrivate(t *testing.T) {
+	xblc := XBLCaptures{}
+	err := json.Unmarshal(openTestFile(t, "XBLCaptures", "clips.json"), &xblc)
+	assert.Nil(t, err)
+
+	r := testReply()
+	out := r.render("captures", CapturesData{GamerTag: "test", Kind: "clips", Captures: xblc.Newest(captureCount)})
+
+	b, err := json.Marshal(newXBLReply(gowon.Message{Nick: "dave", Dest: "#gowon"}, r, out))
+	assert.Nil(t, err)
+	assert.NotContains(t, string(b), "private")
+	assert.Contains(t, string(b), `"contentId"`)
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxLineLength and maxLines bound each line of a reply. The default leaves
+// room within irc's 512 byte limit for the command and channel name.
+var (
+	maxLineLength = 400
+	maxLines      = 3
+)
+
+// formattingRe matches colour and formatting codes in every output mode, which
+// a split must never cut through.
+var formattingRe = regexp.MustCompile(markupRe.String() + `|\x03\d{1,2}(?:,\d{1,2})?|\x0f|\x1b\[[0-9;]*m`)
+
+// splitReply splits each line of out that's longer than maxLen bytes into
+// several lines, at list separators where possible. A line never grows past
+// maxLines lines; what doesn't fit is replaced with "+N more" in lang. A maxLen
+// of 0 leaves out alone.
+func splitReply(lang, out string, maxLen, maxLines int) string {
+	if maxLen <= 0 {
+		return out
+	}
+
+	lines := []string{}
+	for _, line := range strings.Split(out, "\n") {
+		lines = append(lines, splitLine(lang, line, maxLen, maxLines)...)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// fragment is a piece of a line being split, with the list item it came from
+// and whether it ends that item, since an over-long item is hard split into
+// several fragments.
+type fragment struct {
+	text string
+	item int
+	last bool
+}
+
+func splitLine(lang, line string, maxLen, maxLines int) []string {
+	if len(line) <= maxLen {
+		return []string{line}
+	}
+
+	items := splitItems(line)
+
+	frags := []fragment{}
+	for i, item := range items {
+		pieces := []string{item}
+		if len(item) > maxLen {
+			pieces = hardSplit(item, maxLen)
+		}
+
+		for j, p := range pieces {
+			frags = append(frags, fragment{text: p, item: i, last: j == len(pieces)-1})
+		}
+	}
+
+	// pack fragments into lines
+	lines := [][]fragment{{frags[0]}}
+	lengths := []int{len(frags[0].text)}
+
+	for _, f := range frags[1:] {
+		n := len(lines) - 1
+		if lengths[n]+len(", ")+len(f.text) <= maxLen {
+			lines[n] = append(lines[n], f)
+			lengths[n] += len(", ") + len(f.text)
+			continue
+		}
+		lines = append(lines, []fragment{f})
+		lengths = append(lengths, len(f.text))
+	}
+
+	if maxLines > 0 && len(lines) > maxLines {
+		lines = lines[:maxLines]
+
+		shown := []fragment{}
+		for _, l := range lines {
+			shown = append(shown, l...)
+		}
+
+		for {
+			more := fragment{text: render(lang, "more", hiddenItems(len(items), shown))}
+			last := lines[maxLines-1]
+			if len(last) == 0 || len(joinFragments(append(last, more))) <= maxLen {
+				lines[maxLines-1] = append(last, more)
+				break
+			}
+			lines[maxLines-1] = last[:len(last)-1]
+			shown = shown[:len(shown)-1]
+		}
+	}
+
+	out := []string{}
+	for _, l := range lines {
+		out = append(out, joinFragments(l))
+	}
+
+	return out
+}
+
+// hiddenItems counts the items of total that shown doesn't hold all of.
+func hiddenItems(total int, shown []fragment) int {
+	if len(shown) == 0 {
+		return total
+	}
+
+	f := shown[len(shown)-1]
+	if f.last {
+		return total - f.item - 1
+	}
+
+	return total - f.item
+}
+
+func joinFragments(frags []fragment) string {
+	texts := []string{}
+	for _, f := range frags {
+		texts = append(texts, f.text)
+	}
+
+	return strings.Join(texts, ", ")
+}
+
+// splitItems splits a line on the ", " between list items, ignoring commas
+// inside brackets such as "dave (party, session)".
+func splitItems(line string) (out []string) {
+	out = []string{}
+
+	depth := 0
+	start := 0
+
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '(', '[':
+			depth++
+		case ')', ']':
+			if depth > 0 {
+				depth--
+			}
+		case ',':
+			if depth == 0 && i+1 < len(line) && line[i+1] == ' ' {
+				out = append(out, line[start:i])
+				start = i + 2
+				i++
+			}
+		}
+	}
+
+	return append(out, line[start:])
+}
+
+// hardSplit breaks s into pieces of at most maxLen bytes, preferring to break
+// at a space and never inside a rune or formatting code.
+func hardSplit(s string, maxLen int) (out []string) {
+	out = []string{}
+
+	for len(s) > maxLen {
+		cut := safeCut(s, maxLen)
+
+		if space := strings.LastIndex(s[:cut], " "); space > cut/2 {
+			out = append(out, s[:space])
+			s = s[space+1:]
+			continue
+		}
+
+		out = append(out, s[:cut])
+		s = s[cut:]
+	}
+
+	return append(out, s)
+}
+
+// safeCut returns the largest index no greater than n where s can be cut
+// without splitting a rune or a formatting code.
+func safeCut(s string, n int) int {
+	for _, loc := range formattingRe.FindAllStringIndex(s, -1) {
+		if loc[0] < n && n < loc[1] {
+			n = loc[0]
+			break
+		}
+	}
+
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+
+	if n == 0 {
+		// a single code or rune longer than the limit can't be split safely
+		_, size := utf8.DecodeRuneInString(s)
+		if loc := formattingRe.FindStringIndex(s); loc != nil && loc[0] == 0 {
+			size = loc[1]
+		}
+		return size
+	}
+
+	return n
+}
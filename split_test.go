@@ -0,0 +1,92 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitReply(t *testing.T) {
+	cases := map[string]struct {
+		in       string
+		maxLen   int
+		maxLines int
+		expected string
+	}{
+		"short line": {
+			in:       "a1, b2",
+			maxLen:   10,
+			expected: "a1, b2",
+		},
+		"splitting disabled": {
+			in:       "a1, b2, c3, d4",
+			maxLen:   0,
+			expected: "a1, b2, c3, d4",
+		},
+		"split at items": {
+			in:       "a1, b2, c3, d4",
+			maxLen:   6,
+			expected: "a1, b2\nc3, d4",
+		},
+		"capped with more": {
+			in:       "a1, b2, c3, d4, e5, f6, g7, h8, i9",
+			maxLen:   16,
+			maxLines: 2,
+			expected: "a1, b2, c3, d4\ne5, f6, +3 more",
+		},
+		"under the cap": {
+			in:       "a1, b2, c3, d4, e5, f6, g7, h8, i9",
+			maxLen:   16,
+			maxLines: 3,
+			expected: "a1, b2, c3, d4\ne5, f6, g7, h8\ni9",
+		},
+		"commas in brackets": {
+			in:       "dave (party, session), sam (session)",
+			maxLen:   25,
+			expected: "dave (party, session)\nsam (session)",
+		},
+		"colour tags kept whole": {
+			in:       "{green}aaaa{clear}, {red}bbbb{clear}",
+			maxLen:   20,
+			expected: "{green}aaaa{clear}\n{red}bbbb{clear}",
+		},
+		"long item split outside tags": {
+			in:       "{green}abcdefgh{clear}",
+			maxLen:   10,
+			expected: "{green}abc\ndefgh\n{clear}",
+		},
+		"mirc codes kept whole": {
+			in:       "\x0303abcdefgh\x0f",
+			maxLen:   4,
+			expected: "\x0303a\nbcde\nfgh\x0f",
+		},
+		"runes kept whole": {
+			in:       "ééééé",
+			maxLen:   3,
+			expected: "é\né\né\né\né",
+		},
+		"long item split at space": {
+			in:       "aaaa bbbb cccc",
+			maxLen:   10,
+			expected: "aaaa bbbb\ncccc",
+		},
+		"long item counted once in more": {
+			in:       "a1, bbbb cccc dddd eeee ffff gggg hhhh iiii, c3",
+			maxLen:   20,
+			maxLines: 2,
+			expected: "a1\n+2 more",
+		},
+		"each line split separately": {
+			in:       "title\na1, b2, c3, d4",
+			maxLen:   12,
+			maxLines: 1,
+			expected: "title\na1, +3 more",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, splitReply("en", tc.in, tc.maxLen, tc.maxLines))
+		})
+	}
+}
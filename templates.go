@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"log"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// The replies for each language live in templates/<language>.tmpl. Every
+// language is parsed over the english templates, so anything a translation
+// leaves out falls back to english.
+//
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+// templateSets holds every reply format for each language, keyed by name.
+// It's filled in by init, as the template functions themselves render.
+var templateSets map[string]*template.Template
+
+func languageFuncs(lang string) template.FuncMap {
+	return template.FuncMap{
+		"colour":         func(colour, in string) string { return colourString(in, colour) },
+		"cycle":          cycleColour,
+		"colourList":     colourList,
+		"join":           func(sep string, in []string) string { return strings.Join(in, sep) },
+		"each":           func(name string, items any) []string { return renderEach(lang, name, items) },
+		"list":           joinList,
+		"limit":          func(n int, in []string) []string { return limitList(lang, in, n) },
+		"plural":         func(n int, one, other string) string { return pluralForm(lang, n, one, other) },
+		"number":         func(n any) string { return formatNumber(lang, n) },
+		"sentence":       func(in string) string { return strings.TrimSuffix(in, ".") },
+		"rarity":         formatRarity,
+		"estimate":       formatEstimate,
+		"relative":       func(t time.Time) string { return relativeTime(lang, t) },
+		"date":           formatDate,
+		"datetime":       formatDateTime,
+		"presenceColour": presenceColour,
+		"repString":      repString,
+		"repColour":      repColour,
+	}
+}
+
+func init() {
+	sets, err := parseTemplates("")
+	if err != nil {
+		panic(err)
+	}
+
+	templateSets = sets
+}
+
+// parseTemplates parses the templates for every language, then the define
+// blocks in override over each of them, so a config file only needs to
+// contain the replies it changes.
+func parseTemplates(override string) (map[string]*template.Template, error) {
+	sets := map[string]*template.Template{}
+
+	for _, lang := range languages {
+		t, err := template.New(lang).Funcs(languageFuncs(lang)).ParseFS(templateFS, "templates/en.tmpl")
+		if err != nil {
+			return nil, err
+		}
+
+		if lang != "en" {
+			t, err = t.ParseFS(templateFS, fmt.Sprintf("templates/%s.tmpl", lang))
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if override != "" {
+			t, err = t.ParseFiles(override)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		sets[lang] = t
+	}
+
+	return sets, nil
+}
+
+// loadTemplates replaces the default replies with those in path.
+func loadTemplates(path string) error {
+	sets, err := parseTemplates(path)
+	if err != nil {
+		return err
+	}
+
+	templateSets = sets
+
+	return nil
+}
+
+// render executes the named template in lang with data. A broken user
+// template is logged and reported in place of the reply rather than failing
+// the command.
+func render(lang, name string, data any) string {
+	t, ok := templateSets[lang]
+	if !ok {
+		t = templateSets[defaultLanguage]
+	}
+
+	var buf bytes.Buffer
+
+	err := t.ExecuteTemplate(&buf, name, data)
+	if err != nil {
+		log.Print(err)
+		return fmt.Sprintf("Error: couldn't render %s", name)
+	}
+
+	return buf.String()
+}
+
+// renderEach renders the template called name for every item of the slice
+// items, so a reply can list its raw data through a nested template.
+func renderEach(lang, name string, items any) []string {
+	out := []string{}
+
+	v := reflect.ValueOf(items)
+	if v.Kind() != reflect.Slice {
+		return out
+	}
+
+	for i := 0; i < v.Len(); i++ {
+		out = append(out, render(lang, name, v.Index(i).Interface()))
+	}
+
+	return out
+}
+
+func formatRarity(percentage float64) string {
+	return strconv.FormatFloat(percentage, 'f', -1, 64) + "%"
+}
+
+// joinList joins items with commas, except for the last two which are
+// joined with the word last, such as "a, b or c".
+func joinList(last string, items []string) string {
+	if len(items) <= 1 {
+		return strings.Join(items, "")
+	}
+
+	return strings.Join(items[:len(items)-1], ", ") + " " + last + " " + items[len(items)-1]
+}
+
+// formatDate shows the day of t in its own timezone, which callers set to
+// the reader's with time.In.
+func formatDate(t time.Time) string {
+	return t.Format(time.DateOnly)
+}
+
+// formatDateTime shows t in its own timezone, which callers set to the
+// reader's with time.In.
+func formatDateTime(t time.Time) string {
+	return t.Format("2006-01-02 15:04 MST")
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadTemplates(t *testing.T) {
+	cases := map[string]struct {
+		override string
+		lang     string
+		name     string
+		data     any
+		expected string
+		err      bool
+	}{
+		"overridden template": {
+			override: `{{define "set"}}{{.Nick}} is now {{colour "green" .GamerTag}}{{end}}`,
+			lang:     "en",
+			name:     "set",
+			data:     map[string]any{"Nick": "dave", "GamerTag": "test", "Xuid": "1"},
+			expected: "dave is now {green}test{clear}",
+		},
+		"overridden in every language": {
+			override: `{{define "set"}}{{.Nick}} is now {{colour "green" .GamerTag}}{{end}}`,
+			lang:     "de",
+			name:     "set",
+			data:     map[string]any{"Nick": "dave", "GamerTag": "test", "Xuid": "1"},
+			expected: "dave is now {green}test{clear}",
+		},
+		"default kept": {
+			override: `{{define "set"}}{{.Nick}} is now {{.GamerTag}}{{end}}`,
+			lang:     "en",
+			name:     "error.no_user",
+			data:     map[string]any{"User": "test"},
+			expected: "Error: no user found for test",
+		},
+		"translation kept": {
+			override: `{{define "set"}}{{.Nick}} is now {{.GamerTag}}{{end}}`,
+			lang:     "de",
+			name:     "error.no_user",
+			data:     map[string]any{"User": "test"},
+			expected: "Fehler: kein Benutzer test gefunden",
+		},
+		"list functions": {
+			override: `{{define "common"}}{{join " / " (limit 1 (colourList .Games))}}{{end}}`,
+			lang:     "en",
+			name:     "common",
+			data:     map[string]any{"Nicks": []string{"dave"}, "Games": []string{"Halo", "Forza"}, "Limit": 10},
+			expected: "{green}Halo{clear} / +1 more",
+		},
+		"broken template": {
+			override: `{{define "set"}}{{.Nick}`,
+			err:      true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			defaults := templateSets
+			t.Cleanup(func() { templateSets = defaults })
+
+			path := filepath.Join(t.TempDir(), "templates.tmpl")
+			err := os.WriteFile(path, []byte(tc.override), 0644)
+			assert.Nil(t, err)
+
+			err = loadTemplates(path)
+			if tc.err {
+				assert.NotNil(t, err)
+				assert.Equal(t, defaults, templateSets)
+				return
+			}
+			assert.Nil(t, err)
+
+			assert.Equal(t, tc.expected, render(tc.lang, tc.name, tc.data))
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	cases := map[string]struct {
+		lang     string
+		name     string
+		data     any
+		expected string
+	}{
+		"default template": {
+			lang:     "en",
+			name:     "recap.none",
+			data:     map[string]any{"Channel": "#gowon"},
+			expected: "nothing happened on xbox live in #gowon this week",
+		},
+		"translated template": {
+			lang:     "de",
+			name:     "recap.none",
+			data:     map[string]any{"Channel": "#gowon"},
+			expected: "diese Woche ist in #gowon auf Xbox Live nichts passiert",
+		},
+		"untranslated template": {
+			lang:     "de",
+			name:     "friends.friend",
+			data:     XBLPlayer{Gamertag: "dave", PresenceState: "Offline"},
+			expected: "{red}dave{clear}",
+		},
+		"unknown language": {
+			lang:     "fr",
+			name:     "recap.none",
+			data:     map[string]any{"Channel": "#gowon"},
+			expected: "nothing happened on xbox live in #gowon this week",
+		},
+		"localised numbers": {
+			lang:     "de",
+			name:     "recap.gain",
+			data:     map[string]any{"Nick": "dave", "Gain": 1250},
+			expected: "dave +1.250",
+		},
+		"localised plurals": {
+			lang:     "de",
+			name:     "relative.days",
+			data:     1,
+			expected: "vor 1 Tag",
+		},
+		"localised list": {
+			lang:     "de",
+			name:     "usage",
+			data:     map[string]any{"Commands": []string{"[s]et", "[r]ecent", "help"}},
+			expected: "einer von [s]et, [r]ecent oder help muss als Befehl angegeben werden",
+		},
+		"missing template": {
+			lang:     "en",
+			name:     "nope",
+			expected: "Error: couldn't render nope",
+		},
+		"bad data": {
+			lang:     "en",
+			name:     "whoplays",
+			data:     map[string]any{"Title": "not a title"},
+			expected: "Error: couldn't render whoplays",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, render(tc.lang, tc.name, tc.data))
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/boltdb/bolt"
+)
+
+var invalidTimezoneErr = errors.New("invalid timezone")
+
+// parseTimezone loads an IANA timezone such as Europe/London. The zone
+// database is embedded so this works in minimal containers too.
+func parseTimezone(name string) (*time.Location, error) {
+	if strings.EqualFold(name, "utc") {
+		return time.UTC, nil
+	}
+
+	if name == "" || strings.EqualFold(name, "local") {
+		return nil, invalidTimezoneErr
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, invalidTimezoneErr
+	}
+
+	return loc, nil
+}
+
+// userTimezone returns the timezone times are shown in for nick in channel:
+// their own if they've set one, then the channel's, then UTC.
+func userTimezone(kv *bolt.DB, nick, channel string) (*time.Location, error) {
+	name, err := getUserSetting(kv, nick, "timezone")
+	if err != nil {
+		return nil, err
+	}
+
+	if name == "" {
+		name, err = getChannelSetting(kv, channel, "timezone")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if name == "" {
+		return time.UTC, nil
+	}
+
+	// a zone removed from the database since it was set falls back to utc
+	loc, err := parseTimezone(name)
+	if err != nil {
+		return time.UTC, nil
+	}
+
+	return loc, nil
+}
+
+// tzHandler shows or sets the timezone of nick, or of channel when args
+// starts with "channel".
+func tzHandler(kv *bolt.DB, r *reply, nick, channel, args string) (string, error) {
+	name, zone, _ := strings.Cut(strings.TrimSpace(args), " ")
+
+	isChannel := name == "channel"
+
+	owner, get, set := nick, getUserSetting, setUserSetting
+	if isChannel {
+		owner, get, set = channel, getChannelSetting, setChannelSetting
+		name = strings.TrimSpace(zone)
+	}
+
+	data := map[string]any{"Owner": owner, "IsChannel": isChannel}
+
+	if name == "" {
+		current, err := get(kv, owner, "timezone")
+		if err != nil {
+			return "", err
+		}
+
+		data["Zone"] = current
+
+		return r.render("timezone", data), nil
+	}
+
+	loc, err := parseTimezone(name)
+	if errors.Is(err, invalidTimezoneErr) {
+		data["Zone"] = name
+		return r.render("error.bad_timezone", data), nil
+	}
+
+	err = set(kv, owner, "timezone", loc.String())
+	if err != nil {
+		return "", err
+	}
+
+	data["Zone"] = loc.String()
+	data["Now"] = timeNow().In(loc)
+
+	return r.render("timezone.set", data), nil
+}
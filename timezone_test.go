@@ -0,0 +1,110 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTimezone(t *testing.T) {
+	cases := map[string]struct {
+		in       string
+		expected string
+		err      error
+	}{
+		"iana name": {
+			in:       "Europe/London",
+			expected: "Europe/London",
+		},
+		"utc": {
+			in:       "utc",
+			expected: "UTC",
+		},
+		"unknown": {
+			in:  "Mars/Olympus_Mons",
+			err: invalidTimezoneErr,
+		},
+		"empty": {
+			in:  "",
+			err: invalidTimezoneErr,
+		},
+		"server local": {
+			in:  "Local",
+			err: invalidTimezoneErr,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			loc, err := parseTimezone(tc.in)
+			assert.ErrorIs(t, err, tc.err)
+
+			if tc.err != nil {
+				return
+			}
+
+			assert.Equal(t, tc.expected, loc.String())
+		})
+	}
+}
+
+func TestUserTimezone(t *testing.T) {
+	kv := openTestKV(t)
+
+	loc, err := userTimezone(kv, "dave", "#gowon")
+	assert.Nil(t, err)
+	assert.Equal(t, time.UTC, loc)
+
+	err = setChannelSetting(kv, "#gowon", "timezone", "Europe/Berlin")
+	assert.Nil(t, err)
+
+	loc, err = userTimezone(kv, "dave", "#gowon")
+	assert.Nil(t, err)
+	assert.Equal(t, "Europe/Berlin", loc.String())
+
+	err = setUserSetting(kv, "dave", "timezone", "America/New_York")
+	assert.Nil(t, err)
+
+	loc, err = userTimezone(kv, "dave", "#gowon")
+	assert.Nil(t, err)
+	assert.Equal(t, "America/New_York", loc.String())
+
+	loc, err = userTimezone(kv, "sam", "#gowon")
+	assert.Nil(t, err)
+	assert.Equal(t, "Europe/Berlin", loc.String())
+}
+
+func TestTzHandler(t *testing.T) {
+	setTimeNow(t, time.Date(2024, 2, 5, 12, 0, 0, 0, time.UTC))
+
+	kv := openTestKV(t)
+
+	out, err := tzHandler(kv, testReply(), "dave", "#gowon", " ")
+	assert.Nil(t, err)
+	assert.Equal(t, "dave's timezone isn't set, times are shown in the channel's timezone or UTC", out)
+
+	out, err = tzHandler(kv, testReply(), "dave", "#gowon", "Europe/Nowhere")
+	assert.Nil(t, err)
+	assert.Equal(t, "Error: Europe/Nowhere isn't a timezone, use a name like Europe/London or America/New_York", out)
+
+	out, err = tzHandler(kv, testReply(), "dave", "#gowon", "Asia/Tokyo ")
+	assert.Nil(t, err)
+	assert.Equal(t, "dave's timezone set to {cyan}Asia/Tokyo{clear} (it's 21:00 there)", out)
+
+	out, err = tzHandler(kv, testReply(), "dave", "#gowon", "")
+	assert.Nil(t, err)
+	assert.Equal(t, "dave's timezone is {cyan}Asia/Tokyo{clear}", out)
+
+	out, err = tzHandler(kv, testReply(), "dave", "#gowon", "channel")
+	assert.Nil(t, err)
+	assert.Equal(t, "timezone for #gowon isn't set, times are shown in UTC", out)
+
+	out, err = tzHandler(kv, testReply(), "dave", "#gowon", "channel Europe/London")
+	assert.Nil(t, err)
+	assert.Equal(t, "timezone for #gowon set to {cyan}Europe/London{clear} (it's 12:00 there)", out)
+
+	loc, err := userTimezone(kv, "sam", "#gowon")
+	assert.Nil(t, err)
+	assert.Equal(t, "Europe/London", loc.String())
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/imroc/req/v3"
+)
+
+const (
+	titleInfoCacheTTL = time.Hour * 24 * 7
+)
+
+// XBLTitleInfo holds the title metadata that rarely changes, cached by title
+// id.
+type XBLTitleInfo struct {
+	TitleID           string   `json:"titleId"`
+	Name              string   `json:"name"`
+	Devices           []string `json:"devices"`
+	TotalAchievements int      `json:"totalAchievements"`
+	TotalGamerscore   int      `json:"totalGamerscore"`
+	ProductID         string   `json:"productId"`
+}
+
+func (i XBLTitleInfo) StoreLink() string {
+	if i.ProductID == "" {
+		return ""
+	}
+
+	return fmt.Sprintf("https://www.microsoft.com/store/productId/%s", i.ProductID)
+}
+
+// matchPlayedTitles returns the distinct titles across histories matching
+// game, ordered by name.
+func matchPlayedTitles(histories map[string]*XBLTitleHistory, game string) (out []XBLTitle) {
+	out = []XBLTitle{}
+	seen := map[string]bool{}
+
+	for _, n := range sortedNicks(histories) {
+		for _, t := range histories[n].MatchTitles(game) {
+			if !seen[t.TitleID] {
+				seen[t.TitleID] = true
+				out = append(out, t)
+			}
+		}
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Name < out[j].Name
+	})
+
+	return out
+}
+
+func xblGetTitleAchievementsList(client *req.Client, titleID string) (*XBLPlayerTitleAchievements, error) {
+	result := &XBLPlayerTitleAchievements{}
+
+	resp, err := client.R().
+		SetPathParam("id", titleID).
+		SetSuccessResult(&result).
+		Get("https://xbl.io/api/v2/achievements/title/{id}")
+
+	return result, checkResponse(resp, err)
+}
+
+func catalogLookupProductID(client *req.Client, titleID string) (string, error) {
+	result := &DisplayCatalogProducts{}
+
+	resp, err := client.R().
+		SetQueryParams(map[string]string{
+			"alternateId": "XboxTitleId",
+			"value":       titleID,
+			"market":      gamePassMarket,
+			"languages":   gamePassLanguage,
+		}).
+		SetSuccessResult(&result).
+		Get("https://displaycatalog.mp.microsoft.com/v7.0/products/lookup")
+
+	err = checkResponse(resp, err)
+	if err != nil || len(result.Products) == 0 {
+		return "", err
+	}
+
+	return result.Products[0].ProductID, nil
+}
+
+func xblGetTitleInfo(client, catalogClient *req.Client, t XBLTitle) (*XBLTitleInfo, error) {
+	info := &XBLTitleInfo{
+		TitleID:           t.TitleID,
+		Name:              t.Name,
+		Devices:           t.Devices,
+		TotalAchievements: t.Achievement.TotalAchievements,
+		TotalGamerscore:   t.Achievement.TotalGamerscore,
+	}
+
+	achievements, err := xblGetTitleAchievementsList(client, t.TitleID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(achievements.Achievements) != 0 {
+		info.TotalAchievements = len(achievements.Achievements)
+		info.TotalGamerscore = 0
+
+		for _, a := range achievements.Achievements {
+			info.TotalGamerscore += a.Gamerscore()
+		}
+	}
+
+	info.ProductID, err = catalogLookupProductID(catalogClient, t.TitleID)
+	if err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+func cachedTitleInfo(client, catalogClient *req.Client, kv *bolt.DB, t XBLTitle) (*XBLTitleInfo, error) {
+	result := &XBLTitleInfo{}
+
+	found, err := cacheGet(kv, "xboxlive_titleinfo", t.TitleID, titleInfoCacheTTL, result)
+	if err != nil || found {
+		return result, err
+	}
+
+	result, err = xblGetTitleInfo(client, catalogClient, t)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, cachePut(kv, "xboxlive_titleinfo", t.TitleID, result)
+}
+
+func xblTitleInfo(r *reply, info *XBLTitleInfo, isGamePass bool) string {
+	return r.render("info", InfoData{Info: *info, GamePass: isGamePass})
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/imroc/req/v3"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchPlayedTitles(t *testing.T) {
+	cases := map[string]struct {
+		game     string
+		expected []string
+	}{
+		"no match": {
+			game:     "starcraft",
+			expected: []string{},
+		},
+		"played by both": {
+			game:     "halo infinite",
+			expected: []string{"Halo Infinite"},
+		},
+		"ambiguous": {
+			game:     "sea of",
+			expected: []string{"Sea of Stars", "Sea of Thieves 2023 Edition"},
+		},
+	}
+
+	histories := map[string]*XBLTitleHistory{}
+
+	for nick, fn := range map[string]string{"dave": "recent_titles.json", "sam": "has_achievements.json"} {
+		xblthjson := openTestFile(t, "XBLTitleHistory", fn)
+		xblth := &XBLTitleHistory{}
+		err := json.Unmarshal(xblthjson, xblth)
+		assert.Nil(t, err)
+
+		histories[nick] = xblth
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			out := []string{}
+			for _, title := range matchPlayedTitles(histories, tc.game) {
+				out = append(out, title.Name)
+			}
+
+			assert.Equal(t, tc.expected, out)
+		})
+	}
+}
+
+func TestXblGetTitleInfo(t *testing.T) {
+	cases := map[string]struct {
+		xblptafn string
+		dcpfn    string
+		expected *XBLTitleInfo
+	}{
+		"title achievements and product": {
+			xblptafn: "has_achievements.json",
+			dcpfn:    "lookup.json",
+			expected: &XBLTitleInfo{
+				TitleID:           "1670311038",
+				Name:              "Persona 3 Reload",
+				Devices:           []string{"PC", "XboxOne", "XboxSeries"},
+				TotalAchievements: 48,
+				TotalGamerscore:   1000,
+				ProductID:         "9PMQDM08SNK9",
+			},
+		},
+		"no title achievements or product": {
+			xblptafn: "title_no_achievements.json",
+			dcpfn:    "empty.json",
+			expected: &XBLTitleInfo{
+				TitleID:           "1670311038",
+				Name:              "Persona 3 Reload",
+				Devices:           []string{"PC", "XboxOne", "XboxSeries"},
+				TotalAchievements: 0,
+				TotalGamerscore:   1000,
+				ProductID:         "",
+			},
+		},
+	}
+
+	xblthjson := openTestFile(t, "XBLTitleHistory", "recent_titles.json")
+	xblth := XBLTitleHistory{}
+	err := json.Unmarshal(xblthjson, &xblth)
+	assert.Nil(t, err)
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			xblptajson := openTestFile(t, "XBLPlayerTitleAchievements", tc.xblptafn)
+			dcpjson := openTestFile(t, "DisplayCatalogProducts", tc.dcpfn)
+
+			client := req.C()
+			httpmock.ActivateNonDefault(client.GetClient())
+			httpmock.RegisterResponder("GET", "https://xbl.io/api/v2/achievements/title/1670311038", func(request *http.Request) (*http.Response, error) {
+				resp := httpmock.NewBytesResponse(http.StatusOK, xblptajson)
+				return resp, nil
+			})
+			httpmock.RegisterResponder("GET", "https://displaycatalog.mp.microsoft.com/v7.0/products/lookup", func(request *http.Request) (*http.Response, error) {
+				resp := httpmock.NewBytesResponse(http.StatusOK, dcpjson)
+				return resp, nil
+			})
+
+			out, err := xblGetTitleInfo(client, client, xblth.Titles[0])
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, out)
+		})
+	}
+}
+
+func TestXblTitleInfo(t *testing.T) {
+	cases := map[string]struct {
+		info       *XBLTitleInfo
+		isGamePass bool
+		expected   string
+	}{
+		"full info": {
+			info: &XBLTitleInfo{
+				TitleID:           "1670311038",
+				Name:              "Persona 3 Reload",
+				Devices:           []string{"PC", "XboxOne", "XboxSeries"},
+				TotalAchievements: 48,
+				TotalGamerscore:   1000,
+				ProductID:         "9PMQDM08SNK9",
+			},
+			isGamePass: true,
+			expected:   "{cyan}Persona 3 Reload{clear} | {blue}PC, XboxOne, XboxSeries{clear} | {green}Achievements: 48{clear} | {yellow}Gamerscore: 1,000{clear} | {green}Game Pass{clear} | https://www.microsoft.com/store/productId/9PMQDM08SNK9",
+		},
+		"no devices or product": {
+			info: &XBLTitleInfo{
+				TitleID:           "1297287142",
+				Name:              "Halo 3",
+				TotalAchievements: 79,
+				TotalGamerscore:   1750,
+			},
+			isGamePass: false,
+			expected:   "{cyan}Halo 3{clear} | {green}Achievements: 79{clear} | {yellow}Gamerscore: 1,750{clear}",
+		},
+		"game pass only": {
+			info: &XBLTitleInfo{
+				Name:      "Forza Horizon 5",
+				ProductID: "9NBLGGH4PBBM",
+			},
+			isGamePass: true,
+			expected:   "{cyan}Forza Horizon 5{clear} | {green}Game Pass{clear} | https://www.microsoft.com/store/productId/9NBLGGH4PBBM",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			out := xblTitleInfo(testReply(), tc.info, tc.isGamePass)
+			assert.Equal(t, tc.expected, out)
+		})
+	}
+}
+
+func TestCachedTitleInfoErrorStatus(t *testing.T) {
+	kv := openTestKV(t)
+
+	xblthjson := openTestFile(t, "XBLTitleHistory", "recent_titles.json")
+	xblth := XBLTitleHistory{}
+	err := json.Unmarshal(xblthjson, &xblth)
+	assert.Nil(t, err)
+
+	client := req.C()
+	httpmock.ActivateNonDefault(client.GetClient())
+	httpmock.RegisterResponder("GET", "https://xbl.io/api/v2/achievements/title/1670311038", httpmock.NewStringResponder(http.StatusTooManyRequests, ""))
+
+	_, err = cachedTitleInfo(client, client, kv, xblth.Titles[0])
+	assert.ErrorIs(t, err, errorStatusErr)
+
+	found, err := cacheGet(kv, "xboxlive_titleinfo", "1670311038", titleInfoCacheTTL, &XBLTitleInfo{})
+	assert.Nil(t, err)
+	assert.False(t, found)
+}
+
+func TestInfoHandlerGamePassFallback(t *testing.T) {
+	kv := openTestKV(t)
+
+	err := setUser(kv, []byte("dave"), []byte("test"), []byte("test"))
+	assert.Nil(t, err)
+	err = addChannelNick(kv, []byte("#gowon"), []byte("dave"))
+	assert.Nil(t, err)
+
+	history := &XBLTitleHistory{}
+	err = json.Unmarshal(openTestFile(t, "XBLTitleHistory", "recent_titles.json"), history)
+	assert.Nil(t, err)
+	err = cachePut(kv, "xboxlive_titlehistory", "test", history)
+	assert.Nil(t, err)
+
+	client := req.C()
+	registerGamePassResponders(t, client)
+
+	cases := map[string]struct {
+		channel  string
+		game     string
+		expected string
+	}{
+		"unplayed game on game pass": {
+			channel:  "#gowon",
+			game:     "forza",
+			expected: "{cyan}Forza Horizon 5{clear} | {green}Game Pass{clear} | https://www.microsoft.com/store/productId/9NBLGGH4PBBM",
+		},
+		"no players and on game pass": {
+			channel:  "dave",
+			game:     "forza",
+			expected: "{cyan}Forza Horizon 5{clear} | {green}Game Pass{clear} | https://www.microsoft.com/store/productId/9NBLGGH4PBBM",
+		},
+		"unplayed and not on game pass": {
+			channel:  "#gowon",
+			game:     "starfield",
+			expected: "Error: nobody has played any games matching starfield",
+		},
+		"no players and not on game pass": {
+			channel:  "dave",
+			game:     "starfield",
+			expected: "Error: no linked players in dave",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			out, err := infoHandler(client, client, kv, testReply(), tc.channel, tc.game)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, out)
+		})
+	}
+}
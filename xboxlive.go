@@ -3,46 +3,105 @@ package main
 import (
 	"errors"
 	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
+	"unicode"
 
+	"github.com/boltdb/bolt"
 	"github.com/imroc/req/v3"
 )
 
 const (
 	historyDifference = time.Hour * 24 * 30
+	rareCount         = 5
+	rareTitleLimit    = 5
+	nextCount         = 3
+	friendsLimit      = 10
+	commonLimit       = 10
+	captureCount      = 3
+)
+
+var (
+	timeNow = time.Now
+
+	// recentWindow is how far back a title can have been played and still
+	// count as recent, unless a command asks for a different window.
+	recentWindow = historyDifference
 )
 
 var (
 	userNotFoundErr        = errors.New("user not found")
 	userNoTitlesErr        = errors.New("user hasn't played any games")
 	titleNoAchievementsErr = errors.New("title has no achievements")
+	titleNoUnlocksErr      = errors.New("title has no unlocked achievements")
+	invalidWindowErr       = errors.New("invalid time window")
+	ambiguousUserErr       = errors.New("several users match")
+	errorStatusErr         = errors.New("request failed")
 )
 
+// checkResponse turns a response with an error status into an error, so a
+// failed request isn't mistaken for an empty result.
+func checkResponse(resp *req.Response, err error) error {
+	if err != nil {
+		return err
+	}
+
+	if resp.IsErrorState() {
+		return fmt.Errorf("%w: %s", errorStatusErr, resp.Status)
+	}
+
+	return nil
+}
+
+func normaliseTitle(in string) string {
+	var sb strings.Builder
+
+	for _, r := range strings.ToLower(in) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			sb.WriteRune(r)
+		}
+	}
+
+	return sb.String()
+}
+
 func colourString(in, colour string) string {
 	return fmt.Sprintf("{%s}%s{clear}", colour, in)
 }
 
+var listColours = []string{"green", "red", "blue", "orange", "magenta", "cyan", "yellow"}
+
+// cycleColour returns the colour for item n of a list.
+func cycleColour(n int) string {
+	return listColours[n%len(listColours)]
+}
+
 func colourList(in []string) (out []string) {
 	out = []string{}
 
-	colours := []string{"green", "red", "blue", "orange", "magenta", "cyan", "yellow"}
-	cl := len(colours)
-
 	for n, i := range in {
-		c := colours[n%cl]
-		o := colourString(i, c)
+		o := colourString(i, cycleColour(n))
 		out = append(out, o)
 	}
 
 	return out
 }
 
-type XBLXuidSearch struct {
-	People []struct {
-		Xuid     string `json:"xuid"`
-		Gamertag string `json:"gamertag"`
+// limitList keeps the first limit items, replacing the rest with a count.
+func limitList(lang string, in []string, limit int) []string {
+	if len(in) <= limit {
+		return in
 	}
+
+	return append(in[:limit:limit], render(lang, "more", len(in)-limit))
+}
+
+type XBLXuidSearch struct {
+	People []XBLPlayer `json:"people"`
 }
 
 type XBLTitleHistory struct {
@@ -64,14 +123,22 @@ type XBLTitle struct {
 	TitleHistory struct {
 		LastTimePlayed time.Time `json:"lastTimePlayed"`
 	} `json:"titleHistory"`
+	Devices  []string `json:"devices"`
+	GamePass struct {
+		IsGamePass bool `json:"isGamePass"`
+	} `json:"gamePass"`
 }
 
-func (xblth *XBLTitleHistory) RecentNames() (out []string) {
-	out = []string{}
+func (t XBLTitle) Completed() bool {
+	return t.Achievement.ProgressPercentage >= 100
+}
+
+func (xblth *XBLTitleHistory) RecentTitles(window time.Duration) (out []XBLTitle) {
+	out = []XBLTitle{}
 
 	for _, t := range xblth.Titles {
-		if time.Since(t.TitleHistory.LastTimePlayed) < historyDifference {
-			out = append(out, t.Name)
+		if timeNow().Sub(t.TitleHistory.LastTimePlayed) < window {
+			out = append(out, t)
 		}
 	}
 
@@ -86,35 +153,104 @@ func (xblth *XBLTitleHistory) FirstTitleID() (string, error) {
 	return xblth.Titles[0].TitleID, nil
 }
 
-func (xblth *XBLTitleHistory) FirstTitleSummary() (string, error) {
-	if len(xblth.Titles) == 0 {
-		return "", userNoTitlesErr
+// matchNames returns the indexes of names matching name, ignoring case and
+// punctuation. An exact match wins outright, otherwise every name containing
+// name is returned.
+func matchNames(names []string, name string) (out []int) {
+	out = []int{}
+
+	n := normaliseTitle(name)
+	if n == "" {
+		return out
 	}
 
-	t := xblth.Titles[0]
+	for i, nm := range names {
+		tn := normaliseTitle(nm)
 
-	var sb strings.Builder
+		if tn == n {
+			return []int{i}
+		}
 
-	w := func(in, colour string) {
-		s := colourString(in, colour)
-		sb.WriteString(s)
+		if strings.Contains(tn, n) {
+			out = append(out, i)
+		}
 	}
 
-	w(t.Name, "cyan")
+	return out
+}
 
-	sb.WriteString(" | ")
+func (xblth *XBLTitleHistory) MatchTitles(name string) (out []XBLTitle) {
+	out = []XBLTitle{}
 
-	w(fmt.Sprintf("Score: %d/%d", t.Achievement.CurrentGamerscore, t.Achievement.TotalGamerscore), "yellow")
+	names := []string{}
+	for _, t := range xblth.Titles {
+		names = append(names, t.Name)
+	}
 
-	sb.WriteString(" | ")
+	for _, i := range matchNames(names, name) {
+		out = append(out, xblth.Titles[i])
+	}
 
-	w(fmt.Sprintf("Achievements: %d", t.Achievement.CurrentAchievements), "green")
+	return out
+}
+
+// commonTitles returns the titles found in every history, carrying the most
+// recent time any of the players played them, newest first.
+func commonTitles(histories []*XBLTitleHistory) (out []XBLTitle) {
+	out = []XBLTitle{}
+
+	if len(histories) == 0 {
+		return out
+	}
 
-	sb.WriteString(" | ")
+	counts := map[string]int{}
+	latest := map[string]XBLTitle{}
 
-	w(fmt.Sprintf("%d%%", t.Achievement.ProgressPercentage), "magenta")
+	for _, h := range histories {
+		seen := map[string]bool{}
 
-	return sb.String(), nil
+		for _, t := range h.Titles {
+			if seen[t.TitleID] {
+				continue
+			}
+			seen[t.TitleID] = true
+			counts[t.TitleID]++
+
+			l, ok := latest[t.TitleID]
+			if !ok || t.TitleHistory.LastTimePlayed.After(l.TitleHistory.LastTimePlayed) {
+				latest[t.TitleID] = t
+			}
+		}
+	}
+
+	for id, c := range counts {
+		if c == len(histories) {
+			out = append(out, latest[id])
+		}
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].TitleHistory.LastTimePlayed.After(out[j].TitleHistory.LastTimePlayed)
+	})
+
+	return out
+}
+
+func titleMatchError(r *reply, gamerTag, title string, matches []XBLTitle) string {
+	if len(matches) == 0 {
+		return r.render("error.no_title_match", map[string]any{"GamerTag": gamerTag, "Title": title})
+	}
+
+	names := []string{}
+	for _, t := range matches {
+		names = append(names, t.Name)
+	}
+
+	return multipleMatchError(r, title, names)
+}
+
+func multipleMatchError(r *reply, title string, names []string) string {
+	return r.render("error.multiple_matches", map[string]any{"Title": title, "Names": names})
 }
 
 type XBLPlayerTitleAchievements struct {
@@ -155,6 +291,161 @@ type XBLAchievement struct {
 	} `json:"rarity"`
 }
 
+func (a XBLAchievement) Unlocked() bool {
+	return a.ProgressState == "Achieved"
+}
+
+func (a XBLAchievement) TitleName() string {
+	if len(a.TitleAssociations) == 0 {
+		return ""
+	}
+
+	return a.TitleAssociations[0].Name
+}
+
+func (a XBLAchievement) RarityString() string {
+	return formatRarity(a.Rarity.CurrentPercentage)
+}
+
+func (a XBLAchievement) Gamerscore() int {
+	for _, r := range a.Rewards {
+		if r.Type == "Gamerscore" {
+			v, _ := strconv.Atoi(r.Value)
+			return v
+		}
+	}
+
+	return 0
+}
+
+// Estimate parses the estimated time to unlock, which is sent as a .NET
+// TimeSpan ([d.]hh:mm:ss[.fffffff]). Zero means no estimate is available.
+func (a XBLAchievement) Estimate() time.Duration {
+	var days, hours, minutes, seconds int
+
+	ts := a.EstimatedTime
+	if d, rest, ok := strings.Cut(ts, "."); ok && !strings.Contains(d, ":") {
+		days, _ = strconv.Atoi(d)
+		ts = rest
+	}
+	ts, _, _ = strings.Cut(ts, ".")
+
+	_, err := fmt.Sscanf(ts, "%d:%d:%d", &hours, &minutes, &seconds)
+	if err != nil {
+		return 0
+	}
+
+	return time.Duration(days)*time.Hour*24 +
+		time.Duration(hours)*time.Hour +
+		time.Duration(minutes)*time.Minute +
+		time.Duration(seconds)*time.Second
+}
+
+// parseWindow parses a time window such as 7d, 2w or 12h.
+func parseWindow(s string) (time.Duration, error) {
+	var d time.Duration
+	var err error
+
+	switch {
+	case strings.HasSuffix(s, "d") || strings.HasSuffix(s, "w"):
+		n, perr := strconv.Atoi(s[:len(s)-1])
+		err = perr
+		d = time.Duration(n) * time.Hour * 24
+		if strings.HasSuffix(s, "w") {
+			d *= 7
+		}
+	default:
+		d, err = time.ParseDuration(s)
+	}
+
+	if err != nil || d <= 0 {
+		return 0, invalidWindowErr
+	}
+
+	return d, nil
+}
+
+// relativeTime describes how long ago t was, to the largest whole unit.
+func relativeTime(lang string, t time.Time) string {
+	d := timeNow().Sub(t)
+
+	switch {
+	case d < time.Minute:
+		return render(lang, "relative.now", nil)
+	case d < time.Hour:
+		return render(lang, "relative.minutes", int(d.Minutes()))
+	case d < time.Hour*24:
+		return render(lang, "relative.hours", int(d.Hours()))
+	default:
+		return render(lang, "relative.days", int(d.Hours()/24))
+	}
+}
+
+func formatEstimate(d time.Duration) string {
+	if d == 0 {
+		return "?"
+	}
+
+	h := int(d.Hours())
+	m := int(d.Minutes()) % 60
+
+	if h == 0 {
+		return fmt.Sprintf("~%dm", m)
+	}
+
+	if m == 0 {
+		return fmt.Sprintf("~%dh", h)
+	}
+
+	return fmt.Sprintf("~%dh%dm", h, m)
+}
+
+// nextAchievements ranks locked achievements from cheapest to most expensive:
+// shortest estimated time first (unknown estimates last), then most common,
+// then highest gamerscore.
+func nextAchievements(achievements []XBLAchievement, secret bool) (out []XBLAchievement) {
+	out = []XBLAchievement{}
+
+	for _, a := range achievements {
+		if a.Unlocked() || (a.IsSecret && !secret) {
+			continue
+		}
+		out = append(out, a)
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		ei, ej := out[i].Estimate(), out[j].Estimate()
+		if ei != ej {
+			return ej == 0 || (ei != 0 && ei < ej)
+		}
+
+		ri, rj := out[i].Rarity.CurrentPercentage, out[j].Rarity.CurrentPercentage
+		if ri != rj {
+			return ri > rj
+		}
+
+		return out[i].Gamerscore() > out[j].Gamerscore()
+	})
+
+	return out
+}
+
+func rarestAchievements(achievements []XBLAchievement, unlocked bool) (out []XBLAchievement) {
+	out = []XBLAchievement{}
+
+	for _, a := range achievements {
+		if a.Unlocked() == unlocked {
+			out = append(out, a)
+		}
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Rarity.CurrentPercentage < out[j].Rarity.CurrentPercentage
+	})
+
+	return out
+}
+
 func (xblpta *XBLPlayerTitleAchievements) NewestAchievement() (newest XBLAchievement, err error) {
 	if len(xblpta.Achievements) == 0 {
 		return newest, titleNoAchievementsErr
@@ -171,6 +462,133 @@ func (xblpta *XBLPlayerTitleAchievements) NewestAchievement() (newest XBLAchieve
 	return newest, nil
 }
 
+type XBLTimeline struct {
+	First         XBLAchievement `json:"first"`
+	Last          XBLAchievement `json:"last"`
+	Unlocked      int            `json:"unlocked"`
+	Total         int            `json:"total"`
+	ActiveDays    int            `json:"activeDays"`
+	BestDay       time.Time      `json:"bestDay"`
+	BestDayCount  int            `json:"bestDayCount"`
+	LongestStreak int            `json:"longestStreak"`
+}
+
+func (t XBLTimeline) Completed() bool {
+	return t.Unlocked == t.Total
+}
+
+// PerDay is how many achievements were unlocked on each day with unlocks.
+func (t XBLTimeline) PerDay() float64 {
+	return float64(t.Unlocked) / float64(t.ActiveDays)
+}
+
+// Percent is how much of the title has been unlocked.
+func (t XBLTimeline) Percent() int {
+	return t.Unlocked * 100 / t.Total
+}
+
+// Days is how many days passed between the first and last unlock.
+func (t XBLTimeline) Days() int {
+	took := t.Last.Progression.TimeUnlocked.Sub(t.First.Progression.TimeUnlocked)
+	return int(math.Round(took.Hours() / 24))
+}
+
+// Timeline summarises the unlock history of a title, grouping unlocks by
+// calendar day in loc to find the busiest day and the longest run of days
+// with at least one unlock.
+func (xblpta *XBLPlayerTitleAchievements) Timeline(loc *time.Location) (timeline XBLTimeline, err error) {
+	if len(xblpta.Achievements) == 0 {
+		return timeline, titleNoAchievementsErr
+	}
+
+	unlocked := []XBLAchievement{}
+	for _, a := range xblpta.Achievements {
+		if a.Unlocked() {
+			unlocked = append(unlocked, a)
+		}
+	}
+
+	if len(unlocked) == 0 {
+		return timeline, titleNoUnlocksErr
+	}
+
+	sort.SliceStable(unlocked, func(i, j int) bool {
+		return unlocked[i].Progression.TimeUnlocked.Before(unlocked[j].Progression.TimeUnlocked)
+	})
+
+	counts := map[time.Time]int{}
+	days := []time.Time{}
+
+	for _, a := range unlocked {
+		y, m, d := a.Progression.TimeUnlocked.In(loc).Date()
+		day := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+
+		if counts[day] == 0 {
+			days = append(days, day)
+		}
+		counts[day]++
+	}
+
+	streak := 0
+	for n, day := range days {
+		if n > 0 && days[n-1].AddDate(0, 0, 1).Equal(day) {
+			streak++
+		} else {
+			streak = 1
+		}
+
+		timeline.LongestStreak = max(timeline.LongestStreak, streak)
+
+		if counts[day] > timeline.BestDayCount {
+			timeline.BestDay = day
+			timeline.BestDayCount = counts[day]
+		}
+	}
+
+	timeline.First = unlocked[0]
+	timeline.First.Progression.TimeUnlocked = timeline.First.Progression.TimeUnlocked.In(loc)
+	timeline.Last = unlocked[len(unlocked)-1]
+	timeline.Last.Progression.TimeUnlocked = timeline.Last.Progression.TimeUnlocked.In(loc)
+	timeline.Unlocked = len(unlocked)
+	timeline.Total = len(xblpta.Achievements)
+	timeline.ActiveDays = len(days)
+
+	return timeline, nil
+}
+
+type XBLCaptures struct {
+	Values            []XBLCapture `json:"values"`
+	ContinuationToken any          `json:"continuationToken"`
+}
+
+// XBLCapture is a screenshot or game clip. The api's content locators are
+// download and thumbnail urls in the owner's private storage that expire
+// after a few hours, and xbl.io has no public share link for a capture, so
+// they're left out and replies name captures without linking to them.
+type XBLCapture struct {
+	ContentID         string    `json:"contentId"`
+	DurationInSeconds int       `json:"durationInSeconds"`
+	TitleID           int       `json:"titleId"`
+	TitleName         string    `json:"titleName"`
+	CaptureDate       time.Time `json:"captureDate"`
+	UploadDate        time.Time `json:"uploadDate"`
+	ContentType       string    `json:"contentType"`
+}
+
+func (xblc *XBLCaptures) Newest(n int) (out []XBLCapture) {
+	out = append([]XBLCapture{}, xblc.Values...)
+
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].CaptureDate.After(out[j].CaptureDate)
+	})
+
+	if len(out) > n {
+		out = out[:n]
+	}
+
+	return out
+}
+
 type XBLPlayerSummary struct {
 	People []XBLPlayer `json:"people"`
 }
@@ -188,41 +606,149 @@ type XBLPlayer struct {
 		InMultiplayerSession int `json:"InMultiplayerSession"`
 		InParty              int `json:"InParty"`
 	} `json:"multiplayerSummary"`
+	ModernGamertag       string `json:"modernGamertag"`
+	ModernGamertagSuffix string `json:"modernGamertagSuffix"`
+	UniqueModernGamertag string `json:"uniqueModernGamertag"`
+	DisplayPicRaw        string `json:"displayPicRaw"`
+	PreferredColor       struct {
+		PrimaryColor   string `json:"primaryColor"`
+		SecondaryColor string `json:"secondaryColor"`
+		TertiaryColor  string `json:"tertiaryColor"`
+	} `json:"preferredColor"`
+	Detail struct {
+		AccountTier    string `json:"accountTier"`
+		Bio            string `json:"bio"`
+		IsVerified     bool   `json:"isVerified"`
+		Location       string `json:"location"`
+		Tenure         string `json:"tenure"`
+		FollowerCount  int    `json:"followerCount"`
+		FollowingCount int    `json:"followingCount"`
+		HasGamePass    bool   `json:"hasGamePass"`
+	} `json:"detail"`
 }
 
-func (xblp *XBLPlayerSummary) Summary() string {
-	var sb strings.Builder
+func (p XBLPlayer) Online() bool {
+	return p.PresenceState == "Online"
+}
 
-	w := func(in, colour string) {
-		s := colourString(in, colour)
-		sb.WriteString(s)
+func presenceColour(s string) string {
+	if s == "Online" {
+		return "green"
 	}
+	return "red"
+}
 
-	p := xblp.People[0]
+// Friends returns people with online players first, each group ordered by
+// gamertag.
+func (xblp *XBLPlayerSummary) Friends() (out []XBLPlayer) {
+	out = append([]XBLPlayer{}, xblp.People...)
 
-	w(p.Gamertag, "cyan")
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Online() != out[j].Online() {
+			return out[i].Online()
+		}
 
-	sb.WriteString(" | ")
+		return strings.ToLower(out[i].Gamertag) < strings.ToLower(out[j].Gamertag)
+	})
 
-	w(p.GamerScore, "yellow")
+	return out
+}
 
-	sb.WriteString(" | ")
+func (xblp *XBLPlayerSummary) OnlineCount() (count int) {
+	for _, p := range xblp.People {
+		if p.Online() {
+			count++
+		}
+	}
 
-	stateColour := func(s string) string {
-		if s == "Online" {
-			return "green"
+	return count
+}
+
+// userCandidate is one of the players a gamertag search might have meant.
+type userCandidate struct {
+	Xuid     string `json:"xuid"`
+	GamerTag string `json:"gamertag"`
+	// Name is the modern gamertag with its suffix, which tells apart
+	// players sharing a gamertag.
+	Name string `json:"name"`
+}
+
+func newUserCandidate(p XBLPlayer) userCandidate {
+	name := p.UniqueModernGamertag
+	if name == "" {
+		name = p.Gamertag
+	}
+
+	return userCandidate{Xuid: p.Xuid, GamerTag: p.Gamertag, Name: name}
+}
+
+// ambiguousUserError lists the players a gamertag search couldn't choose
+// between.
+type ambiguousUserError struct {
+	Candidates []userCandidate
+}
+
+func (e *ambiguousUserError) Error() string {
+	return fmt.Sprintf("%s: %d candidates", ambiguousUserErr, len(e.Candidates))
+}
+
+func (e *ambiguousUserError) Unwrap() error {
+	return ambiguousUserErr
+}
+
+// Names lists the candidates by their unique modern gamertags.
+func (e *ambiguousUserError) Names() []string {
+	names := []string{}
+
+	for _, c := range e.Candidates {
+		names = append(names, c.Name)
+	}
+
+	return names
+}
+
+// matchPlayer picks the player meant by user out of search results. An exact
+// match on the unique modern gamertag, suffix and all, is preferred, then
+// one on the gamertag. Anything else is only picked if it's the only result.
+func matchPlayer(user string, people []XBLPlayer) (XBLPlayer, error) {
+	if len(people) == 0 {
+		return XBLPlayer{}, userNotFoundErr
+	}
+
+	fields := []func(p XBLPlayer) string{
+		func(p XBLPlayer) string { return p.UniqueModernGamertag },
+		func(p XBLPlayer) string { return p.Gamertag },
+	}
+
+	for _, field := range fields {
+		matches := []XBLPlayer{}
+
+		for _, p := range people {
+			if strings.EqualFold(field(p), user) {
+				matches = append(matches, p)
+			}
+		}
+
+		if len(matches) == 1 {
+			return matches[0], nil
+		}
+
+		if len(matches) > 1 {
+			people = matches
+			break
 		}
-		return "red"
 	}
 
-	w(p.PresenceState, stateColour(p.PresenceState))
+	if len(people) == 1 {
+		return people[0], nil
+	}
 
-	if p.PresenceState == "Online" {
-		sb.WriteString(" | ")
-		sb.WriteString(p.PresenceText)
+	err := &ambiguousUserError{}
+	for _, p := range people {
+		err.Candidates = append(err.Candidates, newUserCandidate(p))
 	}
 
-	return sb.String()
+	return XBLPlayer{}, err
 }
 
 func xblGetXuid(client *req.Client, user string) (string, string, error) {
@@ -237,37 +763,101 @@ func xblGetXuid(client *req.Client, user string) (string, string, error) {
 		return "", "", err
 	}
 
-	if len(result.People) == 0 {
-		return "", "", userNotFoundErr
+	p, err := matchPlayer(user, result.People)
+	if err != nil {
+		return "", "", err
 	}
 
-	return result.People[0].Xuid, result.People[0].Gamertag, nil
+	return p.Xuid, p.Gamertag, nil
 }
 
-func xblRecentGames(client *req.Client, gamerTag, xuid string) (string, error) {
-	result := XBLTitleHistory{}
+// parseOrder normalises how recent titles are sorted, by when they were
+// played or by gamerscore earned.
+func parseOrder(s string) (string, bool) {
+	switch strings.ToLower(s) {
+	case "played":
+		return "played", true
+	case "score", "gamerscore":
+		return "score", true
+	}
 
-	_, err := client.R().
-		SetPathParam("xuid", xuid).
-		SetSuccessResult(&result).
-		Get("https://xbl.io/api/v2/player/titleHistory/{xuid}")
+	return "", false
+}
+
+// parseRecentArgs picks the user, time window and sort order out of the
+// arguments to the recent command, in any order. The words that aren't a
+// window or order are the user, so gamertags with spaces or starting with a
+// digit needn't be quoted.
+func parseRecentArgs(args []string) (user string, window time.Duration, order string) {
+	window = recentWindow
+	order = "played"
+	words := []string{}
+
+	for _, f := range args {
+		if o, ok := parseOrder(f); ok {
+			order = o
+			continue
+		}
+
+		if w, err := parseWindow(f); err == nil {
+			window = w
+			continue
+		}
 
+		words = append(words, f)
+	}
+
+	return strings.Join(words, " "), window, order
+}
+
+// xblRecentGames lists titles played within window, most recently played
+// first, or by gamerscore earned in the window when order is score. Earned
+// gamerscore comes from the stored snapshot closest to the start of the
+// window, so titles not in it count their whole gamerscore.
+func xblRecentGames(client *req.Client, kv *bolt.DB, r *reply, gamerTag, xuid string, window time.Duration, order string) (string, error) {
+	result, err := xblGetTitleHistory(client, xuid)
 	if err != nil {
 		return "", err
 	}
 
-	recent := result.RecentNames()
+	recent := result.RecentTitles(window)
 
 	if len(recent) == 0 {
-		return fmt.Sprintf("%s has no recently played xboxlive games", gamerTag), nil
+		return r.render("recent.none", UserData{GamerTag: gamerTag}), nil
 	}
 
-	cl := colourList(recent)
+	earned := map[string]int{}
 
-	return fmt.Sprintf("%s's recently played xbox live games: %s", gamerTag, strings.Join(cl, ", ")), nil
+	if order == "score" {
+		before, err := getSnapshotAt(kv, xuid, timeNow().Add(-window))
+		if err != nil {
+			return "", err
+		}
+
+		was := map[string]int{}
+		if before != nil {
+			for _, t := range before.Titles {
+				was[t.TitleID] = t.Achievement.CurrentGamerscore
+			}
+		}
+
+		for _, t := range recent {
+			earned[t.TitleID] = t.Achievement.CurrentGamerscore - was[t.TitleID]
+		}
+
+		sort.SliceStable(recent, func(i, j int) bool {
+			return earned[recent[i].TitleID] > earned[recent[j].TitleID]
+		})
+	} else {
+		sort.SliceStable(recent, func(i, j int) bool {
+			return recent[i].TitleHistory.LastTimePlayed.After(recent[j].TitleHistory.LastTimePlayed)
+		})
+	}
+
+	return r.render("recent", TitlesData{GamerTag: gamerTag, Titles: recent, Order: order, Earned: earned}), nil
 }
 
-func xblLastAchievement(client *req.Client, gamerTag, xuid string) (string, error) {
+func xblLastAchievement(client *req.Client, r *reply, gamerTag, xuid string) (string, error) {
 	lastAchievementResult := &XBLTitleHistory{}
 
 	_, err := client.R().
@@ -282,7 +872,7 @@ func xblLastAchievement(client *req.Client, gamerTag, xuid string) (string, erro
 	lastAchievementID, err := lastAchievementResult.FirstTitleID()
 
 	if lastAchievementID == "" {
-		return fmt.Sprintf("%s has not played any games", gamerTag), nil
+		return r.render("achievement.none_played", UserData{GamerTag: gamerTag}), nil
 	}
 
 	if err != nil {
@@ -304,21 +894,20 @@ func xblLastAchievement(client *req.Client, gamerTag, xuid string) (string, erro
 	lastAchievement, err := playerTitleAchievementsResult.NewestAchievement()
 
 	if errors.Is(err, titleNoAchievementsErr) {
-		return fmt.Sprintf("%s has no achievements", gamerTag), err
+		return r.render("achievement.none", UserData{GamerTag: gamerTag}), err
 	}
 
 	if err != nil {
 		return "", err
 	}
 
-	gameName := lastAchievement.TitleAssociations[0].Name
-	achievementName := lastAchievement.Name
-	achievementDesc := strings.TrimSuffix(lastAchievement.Description, ".")
-
-	return fmt.Sprintf("%s's last xbox live achievement: %s - %s (%s)", gamerTag, gameName, achievementName, achievementDesc), nil
+	return r.render("achievement", LastAchievementData{
+		AchievementData: AchievementData{GamerTag: gamerTag, Achievement: lastAchievement},
+		Unlocked:        lastAchievement.Progression.TimeUnlocked.In(r.Loc),
+	}), nil
 }
 
-func xblPlayerSummary(client *req.Client, gamerTag, xuid string) (string, error) {
+func xblPlayerSummary(client *req.Client, r *reply, gamerTag, xuid string) (string, error) {
 	playerSummary := &XBLPlayerSummary{}
 
 	_, err := client.R().
@@ -330,10 +919,10 @@ func xblPlayerSummary(client *req.Client, gamerTag, xuid string) (string, error)
 		return "", err
 	}
 
-	return fmt.Sprintf("Xbox live player summary: %s", playerSummary.Summary()), nil
+	return r.render("player", PlayerData{GamerTag: gamerTag, Player: playerSummary.People[0]}), nil
 }
 
-func xblLastGame(client *req.Client, gamerTag, xuid string) (string, error) {
+func xblLastGame(client *req.Client, r *reply, gamerTag, xuid string) (string, error) {
 	result := &XBLTitleHistory{}
 
 	_, err := client.R().
@@ -345,15 +934,396 @@ func xblLastGame(client *req.Client, gamerTag, xuid string) (string, error) {
 		return "", err
 	}
 
-	summary, err := result.FirstTitleSummary()
+	if len(result.Titles) == 0 {
+		return r.render("last.none", UserData{GamerTag: gamerTag}), nil
+	}
+
+	return r.render("last", LastGameData{
+		TitleData: TitleData{GamerTag: gamerTag, Title: result.Titles[0]},
+		Played:    result.Titles[0].TitleHistory.LastTimePlayed.In(r.Loc),
+	}), nil
+}
+
+func xblGetTitleHistory(client *req.Client, xuid string) (*XBLTitleHistory, error) {
+	result := &XBLTitleHistory{}
+
+	resp, err := client.R().
+		SetPathParam("xuid", xuid).
+		SetSuccessResult(&result).
+		Get("https://xbl.io/api/v2/player/titleHistory/{xuid}")
+
+	return result, checkResponse(resp, err)
+}
+
+func xblGetTitleAchievements(client *req.Client, xuid, titleID string) (*XBLPlayerTitleAchievements, error) {
+	result := &XBLPlayerTitleAchievements{}
+
+	resp, err := client.R().
+		SetPathParam("xuid", xuid).
+		SetPathParam("id", titleID).
+		SetSuccessResult(&result).
+		Get("https://xbl.io/api/v2/achievements/player/{xuid}/{id}")
+
+	return result, checkResponse(resp, err)
+}
+
+func xblGame(client *req.Client, r *reply, gamerTag, xuid, title string) (string, error) {
+	result, err := xblGetTitleHistory(client, xuid)
+	if err != nil {
+		return "", err
+	}
+
+	matches := result.MatchTitles(title)
+
+	if len(matches) != 1 {
+		return titleMatchError(r, gamerTag, title, matches), nil
+	}
+
+	return r.render("game", TitleData{GamerTag: gamerTag, Title: matches[0]}), nil
+}
+
+func xblGetAchievements(client *req.Client, r *reply, gamerTag, xuid, title string) ([]XBLAchievement, string, error) {
+	history, err := xblGetTitleHistory(client, xuid)
+	if err != nil {
+		return nil, "", err
+	}
+
+	titles := history.RecentTitles(recentWindow)
+
+	if title != "" {
+		titles = history.MatchTitles(title)
+
+		if len(titles) != 1 {
+			return nil, titleMatchError(r, gamerTag, title, titles), nil
+		}
+	}
+
+	if len(titles) == 0 {
+		return nil, r.render("recent.none", UserData{GamerTag: gamerTag}), nil
+	}
+
+	if len(titles) > rareTitleLimit {
+		titles = titles[:rareTitleLimit]
+	}
+
+	achievements := []XBLAchievement{}
+
+	for _, t := range titles {
+		result, err := xblGetTitleAchievements(client, xuid, t.TitleID)
+		if err != nil {
+			return nil, "", err
+		}
+
+		achievements = append(achievements, result.Achievements...)
+	}
+
+	return achievements, "", nil
+}
+
+func xblRare(client *req.Client, r *reply, gamerTag, xuid, title string, locked bool) (string, error) {
+	achievements, msg, err := xblGetAchievements(client, r, gamerTag, xuid, title)
+	if msg != "" || err != nil {
+		return msg, err
+	}
+
+	rarest := rarestAchievements(achievements, !locked)
+
+	if locked {
+		if len(rarest) == 0 {
+			return r.render("achievements.none_locked", UserData{GamerTag: gamerTag}), nil
+		}
+
+		return r.render("chase", AchievementData{GamerTag: gamerTag, Achievement: rarest[0]}), nil
+	}
+
+	if len(rarest) == 0 {
+		return r.render("achievements.none_unlocked", UserData{GamerTag: gamerTag}), nil
+	}
+
+	if len(rarest) > rareCount {
+		rarest = rarest[:rareCount]
+	}
+
+	return r.render("rare", AchievementsData{GamerTag: gamerTag, Achievements: rarest}), nil
+}
+
+func xblNext(client *req.Client, r *reply, gamerTag, xuid, title string, secret bool) (string, error) {
+	achievements, msg, err := xblGetAchievements(client, r, gamerTag, xuid, title)
+	if msg != "" || err != nil {
+		return msg, err
+	}
+
+	next := nextAchievements(achievements, secret)
+
+	if len(next) == 0 {
+		return r.render("achievements.none_locked", UserData{GamerTag: gamerTag}), nil
+	}
+
+	if len(next) > nextCount {
+		next = next[:nextCount]
+	}
+
+	return r.render("next", AchievementsData{GamerTag: gamerTag, Achievements: next}), nil
+}
+
+func xblFriends(client *req.Client, r *reply, gamerTag, xuid string) (string, error) {
+	result := &XBLPlayerSummary{}
+
+	_, err := client.R().
+		SetQueryParam("xuid", xuid).
+		SetSuccessResult(&result).
+		Get("https://xbl.io/api/v2/friends")
+
+	if err != nil {
+		return "", err
+	}
+
+	friends := result.Friends()
+
+	if len(friends) == 0 {
+		return r.render("friends.none", UserData{GamerTag: gamerTag}), nil
+	}
+
+	return r.render("friends", FriendsData{GamerTag: gamerTag, Online: result.OnlineCount(), Friends: friends, Limit: friendsLimit}), nil
+}
+
+func xblGetPlayerSummaries(client *req.Client, players map[string]string) (*XBLPlayerSummary, error) {
+	xuids := []string{}
+	for xuid := range players {
+		xuids = append(xuids, url.PathEscape(xuid))
+	}
+	sort.Strings(xuids)
+
+	result := &XBLPlayerSummary{}
+
+	// The xuids are escaped one by one, as a path param would escape the
+	// commas between them too.
+	resp, err := client.R().
+		SetSuccessResult(&result).
+		Get("https://xbl.io/api/v2/player/summary/" + strings.Join(xuids, ","))
+
+	return result, checkResponse(resp, err)
+}
+
+// xblOnline fetches presence for players, a map of xuid to irc nick, in one
+// batched summary call and groups the online players by what they're playing.
+func xblOnline(client *req.Client, r *reply, players map[string]string) (string, error) {
+	result, err := xblGetPlayerSummaries(client, players)
+	if err != nil {
+		return "", err
+	}
+
+	games := map[string][]NickPlayer{}
+
+	for _, p := range result.People {
+		if !p.Online() {
+			continue
+		}
+
+		nick, ok := players[p.Xuid]
+		if !ok {
+			continue
+		}
+
+		games[p.Playing()] = append(games[p.Playing()], NickPlayer{Nick: nick, Player: p})
+	}
+
+	if len(games) == 0 {
+		return r.render("online.none", nil), nil
+	}
+
+	return r.render("online", PlayingData{Games: groupByGame(games)}), nil
+}
+
+// xblParty lists the players in a party or multiplayer session, grouped by
+// what they're playing so people can see who to ask to join.
+func xblParty(client *req.Client, r *reply, players map[string]string) (string, error) {
+	result, err := xblGetPlayerSummaries(client, players)
+	if err != nil {
+		return "", err
+	}
+
+	games := map[string][]NickPlayer{}
+
+	for _, p := range result.People {
+		inParty := p.MultiplayerSummary.InParty > 0
+		inSession := p.MultiplayerSummary.InMultiplayerSession > 0
+
+		if !p.Online() || !(inParty || inSession) {
+			continue
+		}
+
+		nick, ok := players[p.Xuid]
+		if !ok {
+			continue
+		}
+
+		games[p.Playing()] = append(games[p.Playing()], NickPlayer{Nick: nick, Player: p})
+	}
+
+	if len(games) == 0 {
+		return r.render("party.none", nil), nil
+	}
+
+	return r.render("party", PlayingData{Games: groupByGame(games)}), nil
+}
+
+// Playing is what the player is doing, falling back to their presence state
+// when it's not a game.
+func (p XBLPlayer) Playing() string {
+	if p.PresenceText == "" {
+		return p.PresenceState
+	}
+	return p.PresenceText
+}
+
+// groupByGame orders games by how many players are in them, then by name,
+// and the players in each by nick.
+func groupByGame(games map[string][]NickPlayer) (out []GameGroup) {
+	out = []GameGroup{}
+
+	for g, players := range games {
+		sort.Slice(players, func(i, j int) bool { return players[i].Nick < players[j].Nick })
+		out = append(out, GameGroup{Name: g, Players: players})
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if len(out[i].Players) != len(out[j].Players) {
+			return len(out[i].Players) > len(out[j].Players)
+		}
+		return out[i].Name < out[j].Name
+	})
+
+	return out
+}
+
+func sortedNicks(histories map[string]*XBLTitleHistory) []string {
+	nicks := []string{}
+	for n := range histories {
+		nicks = append(nicks, n)
+	}
+	sort.Strings(nicks)
+
+	return nicks
+}
+
+func xblCommon(r *reply, histories map[string]*XBLTitleHistory) string {
+	nicks := sortedNicks(histories)
+
+	hs := []*XBLTitleHistory{}
+	for _, n := range nicks {
+		hs = append(hs, histories[n])
+	}
+
+	common := commonTitles(hs)
+
+	if len(common) == 0 {
+		return r.render("common.none", CommonData{Nicks: nicks})
+	}
+
+	return r.render("common", CommonData{Nicks: nicks, Titles: common, Limit: commonLimit})
+}
+
+func xblWhoPlays(r *reply, histories map[string]*XBLTitleHistory, game string) string {
+	titles := map[string]XBLTitle{}
+	players := []NickTitle{}
+
+	for _, n := range sortedNicks(histories) {
+		matches := histories[n].MatchTitles(game)
+
+		for _, t := range matches {
+			titles[t.TitleID] = t
+		}
+
+		if len(matches) == 1 {
+			players = append(players, NickTitle{Nick: n, Title: matches[0]})
+		}
+	}
+
+	if len(titles) == 0 {
+		return r.render("error.nobody_played", map[string]any{"Game": game})
+	}
+
+	if len(titles) > 1 {
+		matches := []XBLTitle{}
+		for _, t := range titles {
+			matches = append(matches, t)
+		}
+		sort.Slice(matches, func(i, j int) bool { return matches[i].Name < matches[j].Name })
+
+		return titleMatchError(r, "", game, matches)
+	}
+
+	for _, t := range titles {
+		return r.render("whoplays", WhoPlaysData{Title: t, Players: players})
+	}
+
+	return ""
+}
+
+func xblCaptures(client *req.Client, r *reply, gamerTag, xuid, kind string) (string, error) {
+	result := &XBLCaptures{}
+
+	_, err := client.R().
+		SetPathParam("kind", kind).
+		SetQueryParam("xuid", xuid).
+		SetSuccessResult(&result).
+		Get("https://xbl.io/api/v2/dvr/{kind}")
+
+	if err != nil {
+		return "", err
+	}
+
+	name := strings.TrimPrefix(kind, "game")
+
+	newest := result.Newest(captureCount)
+	for i := range newest {
+		newest[i].CaptureDate = newest[i].CaptureDate.In(r.Loc)
+	}
+
+	if len(newest) == 0 {
+		return r.render("captures.none", CapturesData{GamerTag: gamerTag, Kind: name}), nil
+	}
+
+	return r.render("captures", CapturesData{GamerTag: gamerTag, Kind: name, Captures: newest}), nil
+}
+
+func xblTimeline(client *req.Client, r *reply, gamerTag, xuid, title string) (string, error) {
+	history, err := xblGetTitleHistory(client, xuid)
+	if err != nil {
+		return "", err
+	}
+
+	matches := history.MatchTitles(title)
 
-	if errors.Is(err, userNoTitlesErr) {
-		return fmt.Sprintf("%s hasn't played any games", gamerTag), nil
+	if len(matches) != 1 {
+		return titleMatchError(r, gamerTag, title, matches), nil
 	}
 
+	t := matches[0]
+
+	result, err := xblGetTitleAchievements(client, xuid, t.TitleID)
 	if err != nil {
 		return "", err
 	}
 
-	return fmt.Sprintf("%s's last played game: %s", gamerTag, summary), nil
+	timeline, err := result.Timeline(r.Loc)
+
+	data := TimelineData{TitleData: TitleData{GamerTag: gamerTag, Title: t}}
+
+	if errors.Is(err, titleNoAchievementsErr) {
+		return r.render("timeline.no_achievements", data), nil
+	}
+
+	if errors.Is(err, titleNoUnlocksErr) {
+		return r.render("timeline.no_unlocks", data), nil
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	data.Timeline = &timeline
+
+	return r.render("timeline", data), nil
 }
@@ -2,10 +2,13 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -25,6 +28,12 @@ func openTestFile(t *testing.T, endpoint, filename string) []byte {
 	return out
 }
 
+func setTimeNow(t *testing.T, now time.Time) {
+	old := timeNow
+	timeNow = func() time.Time { return now }
+	t.Cleanup(func() { timeNow = old })
+}
+
 func TestColourList(t *testing.T) {
 	cases := map[string]struct {
 		in       []string
@@ -53,7 +62,7 @@ func TestColourList(t *testing.T) {
 	}
 }
 
-func TestXBLTitleHistoryRecentNames(t *testing.T) {
+func TestXBLTitleHistoryRecentTitles(t *testing.T) {
 	cases := map[string]struct {
 		deltas   []time.Duration
 		expected []string
@@ -92,7 +101,10 @@ func TestXBLTitleHistoryRecentNames(t *testing.T) {
 				xblth.Titles = append(xblth.Titles, title)
 			}
 
-			out := xblth.RecentNames()
+			out := []string{}
+			for _, title := range xblth.RecentTitles(historyDifference) {
+				out = append(out, title.Name)
+			}
 
 			assert.Equal(t, tc.expected, out)
 		})
@@ -138,21 +150,14 @@ func TestXBLTitleHistoryFirstTitleID(t *testing.T) {
 	}
 }
 
-func TestXBLTitleHistoryFirstTitleSummary(t *testing.T) {
+func TestTitleSummaryTemplate(t *testing.T) {
 	cases := map[string]struct {
 		xblthfn  string
 		expected string
-		err      error
 	}{
-		"no titles": {
-			xblthfn:  "no_titles.json",
-			expected: "",
-			err:      userNoTitlesErr,
-		},
-		"one title": {
+		"game pass title": {
 			xblthfn:  "recent_titles.json",
-			expected: "{cyan}Persona 3 Reload{clear} | {yellow}Score: 275/1000{clear} | {green}Achievements: 20{clear} | {magenta}28%{clear}",
-			err:      nil,
+			expected: "{cyan}Persona 3 Reload{clear} | {yellow}Score: 275/1,000{clear} | {green}Achievements: 20{clear} | {magenta}28%{clear} | {green}Game Pass{clear}",
 		},
 	}
 
@@ -163,9 +168,52 @@ func TestXBLTitleHistoryFirstTitleSummary(t *testing.T) {
 			err := json.Unmarshal(xblthjson, &xblth)
 			assert.Nil(t, err)
 
-			out, err := xblth.FirstTitleSummary()
+			out := testReply().render("title.summary", xblth.Titles[0])
+			assert.Equal(t, tc.expected, out)
+		})
+	}
+}
+
+func TestXBLTitleHistoryMatchTitles(t *testing.T) {
+	cases := map[string]struct {
+		name     string
+		expected []string
+	}{
+		"empty name": {
+			name:     "",
+			expected: []string{},
+		},
+		"no match": {
+			name:     "minecraft",
+			expected: []string{},
+		},
+		"exact match ignoring case and punctuation": {
+			name:     "halo 3",
+			expected: []string{"Halo 3"},
+		},
+		"partial match": {
+			name:     "metal: hellsinger",
+			expected: []string{"Metal: Hellsinger (Xbox Series X|S & PC)"},
+		},
+		"ambiguous match": {
+			name:     "halo",
+			expected: []string{"Halo Infinite", "Halo: The Master Chief Collection", "Halo 3"},
+		},
+	}
+
+	xblthjson := openTestFile(t, "XBLTitleHistory", "recent_titles.json")
+	xblth := XBLTitleHistory{}
+	err := json.Unmarshal(xblthjson, &xblth)
+	assert.Nil(t, err)
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			out := []string{}
+			for _, title := range xblth.MatchTitles(tc.name) {
+				out = append(out, title.Name)
+			}
+
 			assert.Equal(t, tc.expected, out)
-			assert.ErrorIs(t, tc.err, err)
 		})
 	}
 }
@@ -207,14 +255,241 @@ func TestXBLPlayerTitleAchievementsNewestAchievement(t *testing.T) {
 	}
 }
 
-func TestXBLPlayerSummary(t *testing.T) {
+func TestRarestAchievements(t *testing.T) {
+	cases := map[string]struct {
+		xblptafn string
+		unlocked bool
+		expected []string
+	}{
+		"no achievements": {
+			xblptafn: "title_no_achievements.json",
+			unlocked: true,
+			expected: []string{},
+		},
+		"unlocked": {
+			xblptafn: "has_achievements.json",
+			unlocked: true,
+			expected: []string{"Back on Track", "The Thrill of the Hunt", "There's No \"I\" in \"Team\"", "SEES the Day", "Awakened Power"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			xblptajson := openTestFile(t, "XBLPlayerTitleAchievements", tc.xblptafn)
+			xblpta := XBLPlayerTitleAchievements{}
+			err := json.Unmarshal(xblptajson, &xblpta)
+			assert.Nil(t, err)
+
+			out := []string{}
+			for _, a := range rarestAchievements(xblpta.Achievements, tc.unlocked) {
+				out = append(out, a.Name)
+			}
+
+			assert.Equal(t, tc.expected, out)
+		})
+	}
+}
+
+func TestXBLPlayerTitleAchievementsTimeline(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	assert.Nil(t, err)
+
+	cases := map[string]struct {
+		xblptafn      string
+		loc           *time.Location
+		unlocked      int
+		activeDays    int
+		bestDay       time.Time
+		bestDayCount  int
+		longestStreak int
+		completed     bool
+		err           error
+	}{
+		"no achievements": {
+			xblptafn: "title_no_achievements.json",
+			loc:      time.UTC,
+			err:      titleNoAchievementsErr,
+		},
+		"no unlocked achievements": {
+			xblptafn: "no_unlocked_achievements.json",
+			loc:      time.UTC,
+			err:      titleNoUnlocksErr,
+		},
+		"some unlocked": {
+			xblptafn:      "has_achievements.json",
+			loc:           time.UTC,
+			unlocked:      5,
+			activeDays:    2,
+			bestDay:       time.Date(2024, 2, 2, 0, 0, 0, 0, time.UTC),
+			bestDayCount:  4,
+			longestStreak: 2,
+			completed:     false,
+			err:           nil,
+		},
+		"unlocks grouped by local day": {
+			xblptafn:      "has_achievements.json",
+			loc:           tokyo,
+			unlocked:      5,
+			activeDays:    2,
+			bestDay:       time.Date(2024, 2, 3, 0, 0, 0, 0, time.UTC),
+			bestDayCount:  4,
+			longestStreak: 2,
+			completed:     false,
+			err:           nil,
+		},
+		"completed": {
+			xblptafn:      "completed.json",
+			loc:           time.UTC,
+			unlocked:      5,
+			activeDays:    4,
+			bestDay:       time.Date(2023, 9, 22, 0, 0, 0, 0, time.UTC),
+			bestDayCount:  2,
+			longestStreak: 3,
+			completed:     true,
+			err:           nil,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			xblptajson := openTestFile(t, "XBLPlayerTitleAchievements", tc.xblptafn)
+			xblpta := XBLPlayerTitleAchievements{}
+			err := json.Unmarshal(xblptajson, &xblpta)
+			assert.Nil(t, err)
+
+			out, err := xblpta.Timeline(tc.loc)
+			assert.ErrorIs(t, tc.err, err)
+
+			if tc.err != nil {
+				return
+			}
+
+			assert.Equal(t, tc.unlocked, out.Unlocked)
+			assert.Equal(t, tc.activeDays, out.ActiveDays)
+			assert.Equal(t, tc.bestDay, out.BestDay)
+			assert.Equal(t, tc.bestDayCount, out.BestDayCount)
+			assert.Equal(t, tc.longestStreak, out.LongestStreak)
+			assert.Equal(t, tc.completed, out.Completed())
+		})
+	}
+}
+
+func TestXBLAchievementEstimate(t *testing.T) {
+	cases := map[string]struct {
+		in       string
+		expected time.Duration
+	}{
+		"empty": {
+			in:       "",
+			expected: 0,
+		},
+		"no estimate": {
+			in:       "00:00:00",
+			expected: 0,
+		},
+		"minutes": {
+			in:       "00:15:00",
+			expected: time.Minute * 15,
+		},
+		"fractional seconds": {
+			in:       "01:30:00.5000000",
+			expected: time.Minute * 90,
+		},
+		"days": {
+			in:       "1.02:00:00",
+			expected: time.Hour * 26,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			a := XBLAchievement{EstimatedTime: tc.in}
+
+			assert.Equal(t, tc.expected, a.Estimate())
+		})
+	}
+}
+
+func TestNextAchievements(t *testing.T) {
+	cases := map[string]struct {
+		secret   bool
+		expected []string
+	}{
+		"hide secret": {
+			secret:   false,
+			expected: []string{"Quick Win", "Long Haul", "Collector"},
+		},
+		"show secret": {
+			secret:   true,
+			expected: []string{"Hidden Depths", "Quick Win", "Long Haul", "Collector"},
+		},
+	}
+
+	xblptajson := openTestFile(t, "XBLPlayerTitleAchievements", "estimated_times.json")
+	xblpta := XBLPlayerTitleAchievements{}
+	err := json.Unmarshal(xblptajson, &xblpta)
+	assert.Nil(t, err)
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			out := []string{}
+			for _, a := range nextAchievements(xblpta.Achievements, tc.secret) {
+				out = append(out, a.Name)
+			}
+
+			assert.Equal(t, tc.expected, out)
+		})
+	}
+}
+
+func TestXBLCapturesNewest(t *testing.T) {
+	cases := map[string]struct {
+		xblcfn   string
+		n        int
+		expected []string
+	}{
+		"no captures": {
+			xblcfn:   "empty.json",
+			n:        3,
+			expected: []string{},
+		},
+		"newest first": {
+			xblcfn:   "clips.json",
+			n:        3,
+			expected: []string{"Persona 3 Reload", "Halo Infinite", "Lies of P"},
+		},
+		"fewer than n": {
+			xblcfn:   "screenshots.json",
+			n:        3,
+			expected: []string{"Persona 3 Reload"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			xblcjson := openTestFile(t, "XBLCaptures", tc.xblcfn)
+			xblc := XBLCaptures{}
+			err := json.Unmarshal(xblcjson, &xblc)
+			assert.Nil(t, err)
+
+			out := []string{}
+			for _, c := range xblc.Newest(tc.n) {
+				out = append(out, c.TitleName)
+			}
+
+			assert.Equal(t, tc.expected, out)
+		})
+	}
+}
+
+func TestPlayerSummaryTemplate(t *testing.T) {
 	cases := map[string]struct {
 		xblpsfn  string
 		expected string
 	}{
 		"player online": {
 			xblpsfn:  "player_online.json",
-			expected: "{cyan}player{clear} | {yellow}3225{clear} | {green}Online{clear} | Persona 3 Reload",
+			expected: "{cyan}player{clear} | {yellow}3,225{clear} | {green}Online{clear} | Persona 3 Reload",
 		},
 	}
 
@@ -225,7 +500,7 @@ func TestXBLPlayerSummary(t *testing.T) {
 			err := json.Unmarshal(xblpsjson, &xblps)
 			assert.Nil(t, err)
 
-			out := xblps.Summary()
+			out := testReply().render("player.summary", xblps.People[0])
 			assert.Equal(t, tc.expected, out)
 		})
 	}
@@ -233,19 +508,41 @@ func TestXBLPlayerSummary(t *testing.T) {
 
 func TestXblGetXuid(t *testing.T) {
 	cases := map[string]struct {
-		xblxs    string
-		xuid     string
-		gamerTag string
-		err      error
+		xblxs      string
+		user       string
+		xuid       string
+		gamerTag   string
+		candidates int
+		err        error
 	}{
 		"user exists": {
 			xblxs:    "user_exists.json",
+			user:     "xtacticsx",
 			xuid:     "2533274798129181",
 			gamerTag: "xTACTICSx",
 			err:      nil,
 		},
+		"modern gamertag with suffix": {
+			xblxs:    "user_exists.json",
+			user:     "xTACTICSx#6152",
+			xuid:     "2533274891591060",
+			gamerTag: "xEVIL TACTICSx",
+		},
+		"gamertag": {
+			xblxs:    "user_exists.json",
+			user:     "xtacticsx4475",
+			xuid:     "2535438942016823",
+			gamerTag: "xtacticsx4475",
+		},
+		"several users": {
+			xblxs:      "user_exists.json",
+			user:       "tactics",
+			candidates: 9,
+			err:        ambiguousUserErr,
+		},
 		"user doesn't exist": {
 			xblxs:    "user_doesnt_exist.json",
+			user:     "test",
 			xuid:     "",
 			gamerTag: "",
 			err:      userNotFoundErr,
@@ -258,24 +555,136 @@ func TestXblGetXuid(t *testing.T) {
 
 			client := req.C()
 			httpmock.ActivateNonDefault(client.GetClient())
-			httpmock.RegisterResponder("GET", "https://xbl.io/api/v2/search/test", func(request *http.Request) (*http.Response, error) {
+			httpmock.RegisterResponder("GET", "https://xbl.io/api/v2/search/"+url.PathEscape(tc.user), func(request *http.Request) (*http.Response, error) {
 				resp := httpmock.NewBytesResponse(http.StatusOK, xblxsjson)
 				return resp, nil
 			})
 
-			xuid, gamerTag, err := xblGetXuid(client, "test")
+			xuid, gamerTag, err := xblGetXuid(client, tc.user)
 			assert.Equal(t, tc.xuid, xuid)
 			assert.Equal(t, tc.gamerTag, gamerTag)
-			assert.ErrorIs(t, tc.err, err)
+			assert.ErrorIs(t, err, tc.err)
+
+			var ae *ambiguousUserError
+			if errors.As(err, &ae) {
+				assert.Len(t, ae.Candidates, tc.candidates)
+				assert.Equal(t, "xTACTICSx#6152", ae.Candidates[1].Name)
+			}
+		})
+	}
+}
+
+func TestParseRecentArgs(t *testing.T) {
+	cases := map[string]struct {
+		args   string
+		user   string
+		window time.Duration
+		order  string
+	}{
+		"no args": {
+			args:   " ",
+			window: historyDifference,
+			order:  "played",
+		},
+		"user only": {
+			args:   "test ",
+			user:   "test",
+			window: historyDifference,
+			order:  "played",
+		},
+		"days window": {
+			args:   "test 7d",
+			user:   "test",
+			window: time.Hour * 24 * 7,
+			order:  "played",
+		},
+		"window without user": {
+			args:   "90d score",
+			window: time.Hour * 24 * 90,
+			order:  "score",
+		},
+		"weeks window and gamerscore": {
+			args:   "gamerscore 2w test",
+			user:   "test",
+			window: time.Hour * 24 * 14,
+			order:  "score",
+		},
+		"user with spaces": {
+			args:   "Major Nelson 7d",
+			user:   "Major Nelson",
+			window: time.Hour * 24 * 7,
+			order:  "played",
+		},
+		"window between user words": {
+			args:   "Major 7d Nelson score",
+			user:   "Major Nelson",
+			window: time.Hour * 24 * 7,
+			order:  "score",
+		},
+		"hours window": {
+			args:   "12h",
+			window: time.Hour * 12,
+			order:  "played",
+		},
+		"gamertag starting with a digit": {
+			args:   "2Fast Furious 7d",
+			user:   "2Fast Furious",
+			window: time.Hour * 24 * 7,
+			order:  "played",
+		},
+		"not a window": {
+			args:   "test 7x",
+			user:   "test 7x",
+			window: historyDifference,
+			order:  "played",
+		},
+		"zero window": {
+			args:   "0d",
+			user:   "0d",
+			window: historyDifference,
+			order:  "played",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			user, window, order := parseRecentArgs(strings.Fields(tc.args))
+			assert.Equal(t, tc.user, user)
+			assert.Equal(t, tc.window, window)
+			assert.Equal(t, tc.order, order)
+		})
+	}
+}
+
+func TestRelativeTime(t *testing.T) {
+	now := time.Date(2024, 2, 5, 0, 0, 0, 0, time.UTC)
+	setTimeNow(t, now)
+
+	cases := map[string]struct {
+		delta    time.Duration
+		expected string
+	}{
+		"seconds": {delta: time.Second * 30, expected: "just now"},
+		"minutes": {delta: time.Minute * 5, expected: "5m ago"},
+		"hours":   {delta: time.Hour*3 + time.Minute*59, expected: "3h ago"},
+		"days":    {delta: time.Hour * 24 * 12, expected: "12 days ago"},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, relativeTime("en", now.Add(-tc.delta)))
 		})
 	}
 }
 
 func TestXblRecentGames(t *testing.T) {
 	cases := map[string]struct {
-		xblxs string
-		msg   string
-		err   error
+		xblxs    string
+		snapshot string
+		window   time.Duration
+		order    string
+		msg      string
+		err      error
 	}{
 		"no titles": {
 			xblxs: "no_titles.json",
@@ -284,7 +693,7 @@ func TestXblRecentGames(t *testing.T) {
 		},
 		"recent titles": {
 			xblxs: "recent_titles.json",
-			msg:   "test's recently played xbox live games: {green}Persona 3 Reload{clear}",
+			msg:   "test's recently played xbox live games: {green}Persona 3 Reload{clear} (1 day ago)",
 			err:   nil,
 		},
 		"no recent titles": {
@@ -292,10 +701,44 @@ func TestXblRecentGames(t *testing.T) {
 			msg:   "test has no recently played xboxlive games",
 			err:   nil,
 		},
+		"longer window": {
+			xblxs:  "recent_titles.json",
+			window: time.Hour * 24 * 120,
+			msg:    "test's recently played xbox live games: {green}Persona 3 Reload{clear} (1 day ago), {red}Lies of P{clear} (100 days ago)",
+			err:    nil,
+		},
+		"by score": {
+			xblxs:    "completed_titles.json",
+			snapshot: "recent_titles.json",
+			window:   time.Hour * 24 * 120,
+			order:    "score",
+			msg:      "test's recently played xbox live games: {green}Lies of P{clear} (+495, 3h ago), {red}Persona 3 Reload{clear} (+0, 1 day ago)",
+			err:      nil,
+		},
 	}
 
 	for name, tc := range cases {
 		t.Run(name, func(t *testing.T) {
+			kv := openTestKV(t)
+
+			if tc.snapshot != "" {
+				setTimeNow(t, time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC))
+
+				history := &XBLTitleHistory{}
+				err := json.Unmarshal(openTestFile(t, "XBLTitleHistory", tc.snapshot), history)
+				assert.Nil(t, err)
+
+				err = putSnapshot(kv, "test", history)
+				assert.Nil(t, err)
+			}
+
+			setTimeNow(t, time.Date(2024, 2, 5, 0, 0, 0, 0, time.UTC))
+
+			window := tc.window
+			if window == 0 {
+				window = historyDifference
+			}
+
 			xblxsjson := openTestFile(t, "XBLTitleHistory", tc.xblxs)
 
 			client := req.C()
@@ -305,7 +748,7 @@ func TestXblRecentGames(t *testing.T) {
 				return resp, nil
 			})
 
-			out, err := xblRecentGames(client, "test", "test")
+			out, err := xblRecentGames(client, kv, testReply(), "test", "test", window, tc.order)
 			assert.Equal(t, tc.msg, out)
 			assert.ErrorIs(t, tc.err, err)
 		})
@@ -313,6 +756,8 @@ func TestXblRecentGames(t *testing.T) {
 }
 
 func TestXblLastAchievement(t *testing.T) {
+	setTimeNow(t, time.Date(2024, 2, 5, 0, 0, 0, 0, time.UTC))
+
 	cases := map[string]struct {
 		xblth   string
 		xblpta  string
@@ -324,7 +769,7 @@ func TestXblLastAchievement(t *testing.T) {
 			xblth:   "has_achievements.json",
 			xblpta:  "has_achievements.json",
 			titleId: "1670311038",
-			msg:     "test's last xbox live achievement: Persona 3 Reload - Back on Track (Defeated the Priestess)",
+			msg:     "test's last xbox live achievement: Persona 3 Reload - Back on Track (Defeated the Priestess) | unlocked 1 day ago (2024-02-03 17:12 UTC)",
 			err:     nil,
 		},
 		"no titles": {
@@ -359,7 +804,7 @@ func TestXblLastAchievement(t *testing.T) {
 				return resp, nil
 			})
 
-			out, err := xblLastAchievement(client, "test", "test")
+			out, err := xblLastAchievement(client, testReply(), "test", "test")
 			assert.Equal(t, tc.msg, out)
 			assert.ErrorIs(t, tc.err, err)
 		})
@@ -374,12 +819,17 @@ func TestXblPlayerSummary(t *testing.T) {
 	}{
 		"player online": {
 			xblpsfn:  "player_online.json",
-			expected: "Xbox live player summary: {cyan}player{clear} | {yellow}3225{clear} | {green}Online{clear} | Persona 3 Reload",
+			expected: "Xbox live player summary: {cyan}player{clear} | {yellow}3,225{clear} | {green}Online{clear} | Persona 3 Reload",
 			err:      nil,
 		},
 		"player offline": {
 			xblpsfn:  "player_offline.json",
-			expected: "Xbox live player summary: {cyan}graffsu7{clear} | {yellow}2466{clear} | {red}Offline{clear}",
+			expected: "Xbox live player summary: {cyan}graffsu7{clear} | {yellow}2,466{clear} | {red}Offline{clear}",
+			err:      nil,
+		},
+		"player in party": {
+			xblpsfn:  "party.json",
+			expected: "Xbox live player summary: {cyan}dave{clear} | {yellow}8,855{clear} | {green}Online{clear} | Halo Infinite | {magenta}in party{clear} | {blue}in multiplayer session{clear}",
 			err:      nil,
 		},
 	}
@@ -395,7 +845,7 @@ func TestXblPlayerSummary(t *testing.T) {
 				return resp, nil
 			})
 
-			out, err := xblPlayerSummary(client, "test", "test")
+			out, err := xblPlayerSummary(client, testReply(), "test", "test")
 			assert.Equal(t, tc.expected, out)
 			assert.ErrorIs(t, tc.err, err)
 		})
@@ -403,18 +853,40 @@ func TestXblPlayerSummary(t *testing.T) {
 }
 
 func TestXblLastGame(t *testing.T) {
+	setTimeNow(t, time.Date(2024, 2, 5, 0, 0, 0, 0, time.UTC))
+
+	london, err := time.LoadLocation("Europe/London")
+	assert.Nil(t, err)
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	assert.Nil(t, err)
+
 	cases := map[string]struct {
 		xblthfn  string
+		loc      *time.Location
 		expected string
 		err      error
 	}{
 		"has played": {
 			xblthfn:  "recent_titles.json",
-			expected: "test's last played game: {cyan}Persona 3 Reload{clear} | {yellow}Score: 275/1000{clear} | {green}Achievements: 20{clear} | {magenta}28%{clear}",
+			loc:      time.UTC,
+			expected: "test's last played game: {cyan}Persona 3 Reload{clear} | {yellow}Score: 275/1,000{clear} | {green}Achievements: 20{clear} | {magenta}28%{clear} | {green}Game Pass{clear} | played 1 day ago (2024-02-03 14:01 UTC)",
+			err:      nil,
+		},
+		"in the reader's timezone": {
+			xblthfn:  "recent_titles.json",
+			loc:      tokyo,
+			expected: "test's last played game: {cyan}Persona 3 Reload{clear} | {yellow}Score: 275/1,000{clear} | {green}Achievements: 20{clear} | {magenta}28%{clear} | {green}Game Pass{clear} | played 1 day ago (2024-02-03 23:01 JST)",
+			err:      nil,
+		},
+		"in winter time": {
+			xblthfn:  "recent_titles.json",
+			loc:      london,
+			expected: "test's last played game: {cyan}Persona 3 Reload{clear} | {yellow}Score: 275/1,000{clear} | {green}Achievements: 20{clear} | {magenta}28%{clear} | {green}Game Pass{clear} | played 1 day ago (2024-02-03 14:01 GMT)",
 			err:      nil,
 		},
 		"hasn't played": {
 			xblthfn:  "no_titles.json",
+			loc:      time.UTC,
 			expected: "test hasn't played any games",
 			err:      nil,
 		},
@@ -431,7 +903,518 @@ func TestXblLastGame(t *testing.T) {
 				return resp, nil
 			})
 
-			out, err := xblLastGame(client, "test", "test")
+			out, err := xblLastGame(client, newReply("en", tc.loc), "test", "test")
+			assert.Equal(t, tc.expected, out)
+			assert.ErrorIs(t, tc.err, err)
+		})
+	}
+}
+
+func TestXblGame(t *testing.T) {
+	cases := map[string]struct {
+		xblthfn  string
+		title    string
+		expected string
+		err      error
+	}{
+		"no titles": {
+			xblthfn:  "no_titles.json",
+			title:    "halo",
+			expected: "Error: test hasn't played any games matching halo",
+			err:      nil,
+		},
+		"one match": {
+			xblthfn:  "recent_titles.json",
+			title:    "lies of p",
+			expected: "test's progress: {cyan}Lies of P{clear} | {yellow}Score: 505/1,000{clear} | {green}Achievements: 26{clear} | {magenta}50%{clear} | {green}Game Pass{clear}",
+			err:      nil,
+		},
+		"not on game pass": {
+			xblthfn:  "recent_titles.json",
+			title:    "halo 3",
+			expected: "test's progress: {cyan}Halo 3{clear} | {yellow}Score: 25/1,750{clear} | {green}Achievements: 2{clear} | {magenta}1%{clear}",
+			err:      nil,
+		},
+		"multiple matches": {
+			xblthfn:  "recent_titles.json",
+			title:    "guitar hero",
+			expected: "multiple games match guitar hero: {green}Guitar Hero World Tour{clear}, {red}Guitar Hero III{clear}",
+			err:      nil,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			xblthjson := openTestFile(t, "XBLTitleHistory", tc.xblthfn)
+
+			client := req.C()
+			httpmock.ActivateNonDefault(client.GetClient())
+			httpmock.RegisterResponder("GET", "https://xbl.io/api/v2/player/titleHistory/test", func(request *http.Request) (*http.Response, error) {
+				resp := httpmock.NewBytesResponse(http.StatusOK, xblthjson)
+				return resp, nil
+			})
+
+			out, err := xblGame(client, testReply(), "test", "test", tc.title)
+			assert.Equal(t, tc.expected, out)
+			assert.ErrorIs(t, tc.err, err)
+		})
+	}
+}
+
+func TestXblRare(t *testing.T) {
+	cases := map[string]struct {
+		xblthfn  string
+		title    string
+		locked   bool
+		expected string
+		err      error
+	}{
+		"no recent titles": {
+			xblthfn:  "no_recent_titles.json",
+			expected: "test has no recently played xboxlive games",
+			err:      nil,
+		},
+		"recent titles": {
+			xblthfn:  "has_achievements.json",
+			expected: "test's rarest xbox live achievements: {green}Persona 3 Reload - Back on Track (14.89%){clear}, {red}Persona 3 Reload - The Thrill of the Hunt (26.58%){clear}, {blue}Persona 3 Reload - There's No \"I\" in \"Team\" (32.21%){clear}, {orange}Persona 3 Reload - SEES the Day (49.36%){clear}, {magenta}Persona 3 Reload - Awakened Power (55.98%){clear}",
+			err:      nil,
+		},
+		"locked": {
+			xblthfn:  "has_achievements.json",
+			locked:   true,
+			expected: "test's rarest locked achievement: Persona 3 Reload - The Great Seal (Sealed Nyx) {yellow}0.01%{clear}",
+			err:      nil,
+		},
+		"title without achievements": {
+			xblthfn:  "has_achievements.json",
+			title:    "sea of stars",
+			expected: "test has no unlocked achievements",
+			err:      nil,
+		},
+		"title not found": {
+			xblthfn:  "has_achievements.json",
+			title:    "halo 3",
+			expected: "Error: test hasn't played any games matching halo 3",
+			err:      nil,
+		},
+	}
+
+	setTimeNow(t, time.Date(2024, 2, 4, 0, 0, 0, 0, time.UTC))
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			xblthjson := openTestFile(t, "XBLTitleHistory", tc.xblthfn)
+			xblptajson := openTestFile(t, "XBLPlayerTitleAchievements", "has_achievements.json")
+			emptyjson := openTestFile(t, "XBLPlayerTitleAchievements", "title_no_achievements.json")
+
+			client := req.C()
+			httpmock.ActivateNonDefault(client.GetClient())
+			httpmock.RegisterResponder("GET", "https://xbl.io/api/v2/player/titleHistory/test", func(request *http.Request) (*http.Response, error) {
+				resp := httpmock.NewBytesResponse(http.StatusOK, xblthjson)
+				return resp, nil
+			})
+			httpmock.RegisterResponder("GET", "https://xbl.io/api/v2/achievements/player/test/1670311038", func(request *http.Request) (*http.Response, error) {
+				resp := httpmock.NewBytesResponse(http.StatusOK, xblptajson)
+				return resp, nil
+			})
+			httpmock.RegisterResponder("GET", "https://xbl.io/api/v2/achievements/player/test/1613348546", func(request *http.Request) (*http.Response, error) {
+				resp := httpmock.NewBytesResponse(http.StatusOK, emptyjson)
+				return resp, nil
+			})
+
+			out, err := xblRare(client, testReply(), "test", "test", tc.title, tc.locked)
+			assert.Equal(t, tc.expected, out)
+			assert.ErrorIs(t, tc.err, err)
+		})
+	}
+}
+
+func TestXblNext(t *testing.T) {
+	cases := map[string]struct {
+		xblptafn string
+		secret   bool
+		expected string
+		err      error
+	}{
+		"no locked achievements": {
+			xblptafn: "title_no_achievements.json",
+			expected: "test has no locked achievements left",
+			err:      nil,
+		},
+		"locked achievements": {
+			xblptafn: "estimated_times.json",
+			expected: "test's next xbox live achievements: {green}Halo Infinite - Quick Win (Win a match) [15G, 61.3%, ~30m]{clear}, {red}Halo Infinite - Long Haul (Play 100 matches) [50G, 4.2%, ~26h]{clear}, {blue}Halo Infinite - Collector (Collect every skull) [30G, 8.1%, ?]{clear}",
+			err:      nil,
+		},
+		"secret achievements": {
+			xblptafn: "estimated_times.json",
+			secret:   true,
+			expected: "test's next xbox live achievements: {green}Halo Infinite - Hidden Depths (Find the secret room) [20G, 35.7%, ~10m]{clear}, {red}Halo Infinite - Quick Win (Win a match) [15G, 61.3%, ~30m]{clear}, {blue}Halo Infinite - Long Haul (Play 100 matches) [50G, 4.2%, ~26h]{clear}",
+			err:      nil,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			xblthjson := openTestFile(t, "XBLTitleHistory", "recent_titles.json")
+			xblptajson := openTestFile(t, "XBLPlayerTitleAchievements", tc.xblptafn)
+
+			client := req.C()
+			httpmock.ActivateNonDefault(client.GetClient())
+			httpmock.RegisterResponder("GET", "https://xbl.io/api/v2/player/titleHistory/test", func(request *http.Request) (*http.Response, error) {
+				resp := httpmock.NewBytesResponse(http.StatusOK, xblthjson)
+				return resp, nil
+			})
+			httpmock.RegisterResponder("GET", "https://xbl.io/api/v2/achievements/player/test/2043073184", func(request *http.Request) (*http.Response, error) {
+				resp := httpmock.NewBytesResponse(http.StatusOK, xblptajson)
+				return resp, nil
+			})
+
+			out, err := xblNext(client, testReply(), "test", "test", "halo infinite", tc.secret)
+			assert.Equal(t, tc.expected, out)
+			assert.ErrorIs(t, tc.err, err)
+		})
+	}
+}
+
+func TestXblFriends(t *testing.T) {
+	cases := map[string]struct {
+		xblpsfn  string
+		expected string
+		err      error
+	}{
+		"no friends": {
+			xblpsfn:  "no_friends.json",
+			expected: "test has no xbox live friends",
+			err:      nil,
+		},
+		"friends": {
+			xblpsfn:  "friends.json",
+			expected: "test's xbox live friends (2/4 online): {green}kim{clear} (Forza Horizon 5), {green}sam{clear} (Halo Infinite), {red}dave{clear}, {red}lee{clear}",
+			err:      nil,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			xblpsjson := openTestFile(t, "XBLPlayerSummary", tc.xblpsfn)
+
+			client := req.C()
+			httpmock.ActivateNonDefault(client.GetClient())
+			httpmock.RegisterResponder("GET", "https://xbl.io/api/v2/friends?xuid=test", func(request *http.Request) (*http.Response, error) {
+				resp := httpmock.NewBytesResponse(http.StatusOK, xblpsjson)
+				return resp, nil
+			})
+
+			out, err := xblFriends(client, testReply(), "test", "test")
+			assert.Equal(t, tc.expected, out)
+			assert.ErrorIs(t, tc.err, err)
+		})
+	}
+}
+
+func TestXblOnline(t *testing.T) {
+	cases := map[string]struct {
+		xblpsfn  string
+		players  map[string]string
+		url      string
+		expected string
+		err      error
+	}{
+		"nobody online": {
+			xblpsfn:  "player_offline.json",
+			players:  map[string]string{"2533274812012273": "dave"},
+			url:      "https://xbl.io/api/v2/player/summary/2533274812012273",
+			expected: "nobody is online on xbox live",
+			err:      nil,
+		},
+		"grouped by game": {
+			xblpsfn: "friends.json",
+			players: map[string]string{
+				"2533274800000001": "dave",
+				"2533274800000002": "sam",
+				"2533274800000003": "kim",
+			},
+			url:      "https://xbl.io/api/v2/player/summary/2533274800000001,2533274800000002,2533274800000003",
+			expected: "online on xbox live: {green}Forza Horizon 5{clear}: kim | {red}Halo Infinite{clear}: sam",
+			err:      nil,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			xblpsjson := openTestFile(t, "XBLPlayerSummary", tc.xblpsfn)
+
+			client := req.C()
+			httpmock.ActivateNonDefault(client.GetClient())
+			httpmock.ZeroCallCounters()
+			httpmock.RegisterResponder("GET", tc.url, func(request *http.Request) (*http.Response, error) {
+				resp := httpmock.NewBytesResponse(http.StatusOK, xblpsjson)
+				return resp, nil
+			})
+
+			out, err := xblOnline(client, testReply(), tc.players)
+			assert.Equal(t, tc.expected, out)
+			assert.ErrorIs(t, tc.err, err)
+			assert.Equal(t, 1, httpmock.GetCallCountInfo()["GET "+tc.url])
+			assert.Equal(t, 1, httpmock.GetTotalCallCount())
+		})
+	}
+}
+
+func TestXblParty(t *testing.T) {
+	cases := map[string]struct {
+		xblpsfn  string
+		players  map[string]string
+		url      string
+		expected string
+		err      error
+	}{
+		"nobody in a party": {
+			xblpsfn:  "friends.json",
+			players:  map[string]string{"2533274800000002": "sam", "2533274800000003": "kim"},
+			url:      "https://xbl.io/api/v2/player/summary/2533274800000002,2533274800000003",
+			expected: "nobody is in a party or multiplayer session on xbox live",
+			err:      nil,
+		},
+		"grouped by game": {
+			xblpsfn: "party.json",
+			players: map[string]string{
+				"2533274800000001": "dave",
+				"2533274800000002": "sam",
+				"2533274800000003": "kim",
+				"2533274800000004": "lee",
+			},
+			url:      "https://xbl.io/api/v2/player/summary/2533274800000001,2533274800000002,2533274800000003,2533274800000004",
+			expected: "partied up on xbox live: {green}Halo Infinite{clear}: dave (party, session), sam (session) | {red}Forza Horizon 5{clear}: kim (party)",
+			err:      nil,
+		},
+		"unlinked players ignored": {
+			xblpsfn:  "party.json",
+			players:  map[string]string{"2533274800000003": "kim"},
+			url:      "https://xbl.io/api/v2/player/summary/2533274800000003",
+			expected: "partied up on xbox live: {green}Forza Horizon 5{clear}: kim (party)",
+			err:      nil,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			xblpsjson := openTestFile(t, "XBLPlayerSummary", tc.xblpsfn)
+
+			client := req.C()
+			httpmock.ActivateNonDefault(client.GetClient())
+			httpmock.ZeroCallCounters()
+			httpmock.RegisterResponder("GET", tc.url, func(request *http.Request) (*http.Response, error) {
+				resp := httpmock.NewBytesResponse(http.StatusOK, xblpsjson)
+				return resp, nil
+			})
+
+			out, err := xblParty(client, testReply(), tc.players)
+			assert.Equal(t, tc.expected, out)
+			assert.ErrorIs(t, tc.err, err)
+			assert.Equal(t, 1, httpmock.GetCallCountInfo()["GET "+tc.url])
+			assert.Equal(t, 1, httpmock.GetTotalCallCount())
+		})
+	}
+}
+
+func TestXblCommon(t *testing.T) {
+	cases := map[string]struct {
+		xblthfns map[string]string
+		expected string
+	}{
+		"nothing in common": {
+			xblthfns: map[string]string{"dave": "recent_titles.json", "sam": "no_recent_titles.json"},
+			expected: "dave, sam have no games in common",
+		},
+		"games in common": {
+			xblthfns: map[string]string{"dave": "recent_titles.json", "sam": "has_achievements.json"},
+			expected: "games in common for dave, sam: {green}Persona 3 Reload{clear}, {red}Halo Infinite{clear}, {blue}Halo: The Master Chief Collection{clear}",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			histories := map[string]*XBLTitleHistory{}
+
+			for nick, fn := range tc.xblthfns {
+				xblthjson := openTestFile(t, "XBLTitleHistory", fn)
+				xblth := &XBLTitleHistory{}
+				err := json.Unmarshal(xblthjson, xblth)
+				assert.Nil(t, err)
+
+				histories[nick] = xblth
+			}
+
+			out := xblCommon(testReply(), histories)
+			assert.Equal(t, tc.expected, out)
+		})
+	}
+}
+
+func TestXblWhoPlays(t *testing.T) {
+	cases := map[string]struct {
+		game     string
+		expected string
+	}{
+		"nobody": {
+			game:     "starcraft",
+			expected: "Error: nobody has played any games matching starcraft",
+		},
+		"one title": {
+			game:     "halo infinite",
+			expected: "{cyan}Halo Infinite{clear} is played by: dave (22%), sam (12%)",
+		},
+		"one player": {
+			game:     "lies of p",
+			expected: "{cyan}Lies of P{clear} is played by: dave (50%)",
+		},
+		"ambiguous": {
+			game:     "guitar hero",
+			expected: "multiple games match guitar hero: {green}Guitar Hero III{clear}, {red}Guitar Hero World Tour{clear}",
+		},
+	}
+
+	histories := map[string]*XBLTitleHistory{}
+
+	for nick, fn := range map[string]string{"dave": "recent_titles.json", "sam": "has_achievements.json"} {
+		xblthjson := openTestFile(t, "XBLTitleHistory", fn)
+		xblth := &XBLTitleHistory{}
+		err := json.Unmarshal(xblthjson, xblth)
+		assert.Nil(t, err)
+
+		histories[nick] = xblth
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			out := xblWhoPlays(testReply(), histories, tc.game)
+			assert.Equal(t, tc.expected, out)
+		})
+	}
+}
+
+func TestXblCaptures(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	assert.Nil(t, err)
+
+	cases := map[string]struct {
+		xblcfn   string
+		kind     string
+		loc      *time.Location
+		expected string
+		err      error
+	}{
+		"no clips": {
+			xblcfn:   "empty.json",
+			kind:     "gameclips",
+			loc:      time.UTC,
+			expected: "test has no xbox live clips",
+			err:      nil,
+		},
+		"clips": {
+			xblcfn:   "clips.json",
+			kind:     "gameclips",
+			loc:      time.UTC,
+			expected: "test's latest xbox live clips: {green}Persona 3 Reload (2024-02-02){clear}, {red}Halo Infinite (2024-01-20){clear}, {blue}Lies of P (2023-10-26){clear}",
+			err:      nil,
+		},
+		"clips in the reader's timezone": {
+			xblcfn:   "clips.json",
+			kind:     "gameclips",
+			loc:      tokyo,
+			expected: "test's latest xbox live clips: {green}Persona 3 Reload (2024-02-03){clear}, {red}Halo Infinite (2024-01-21){clear}, {blue}Lies of P (2023-10-27){clear}",
+			err:      nil,
+		},
+		"screenshots": {
+			xblcfn:   "screenshots.json",
+			kind:     "screenshots",
+			loc:      time.UTC,
+			expected: "test's latest xbox live screenshots: {green}Persona 3 Reload (2024-02-03){clear}",
+			err:      nil,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			xblcjson := openTestFile(t, "XBLCaptures", tc.xblcfn)
+
+			client := req.C()
+			httpmock.ActivateNonDefault(client.GetClient())
+			httpmock.RegisterResponder("GET", fmt.Sprintf("https://xbl.io/api/v2/dvr/%s?xuid=test", tc.kind), func(request *http.Request) (*http.Response, error) {
+				resp := httpmock.NewBytesResponse(http.StatusOK, xblcjson)
+				return resp, nil
+			})
+
+			out, err := xblCaptures(client, newReply("en", tc.loc), "test", "test", tc.kind)
+			assert.Equal(t, tc.expected, out)
+			assert.ErrorIs(t, tc.err, err)
+		})
+	}
+}
+
+func TestXblTimeline(t *testing.T) {
+	cases := map[string]struct {
+		title    string
+		xblptafn string
+		titleId  string
+		expected string
+		err      error
+	}{
+		"not played": {
+			title:    "halo 3",
+			xblptafn: "empty.json",
+			titleId:  "none",
+			expected: "Error: test hasn't played any games matching halo 3",
+			err:      nil,
+		},
+		"no unlocks": {
+			title:    "starfield",
+			xblptafn: "no_unlocked_achievements.json",
+			titleId:  "2079794073",
+			expected: "test hasn't unlocked any achievements in Starfield",
+			err:      nil,
+		},
+		"in progress": {
+			title:    "persona 3 reload",
+			xblptafn: "has_achievements.json",
+			titleId:  "1670311038",
+			expected: "test's timeline for {cyan}Persona 3 Reload{clear}: 5/48 achievements unlocked\n" +
+				"first unlock: 2024-02-02 15:35 UTC (Awakened Power) | last unlock: 2024-02-03 17:12 UTC (Back on Track)\n" +
+				"5 unlocks over 2 days (2.5/day) | best day: 2024-02-02 (4) | longest streak: 2 days\n" +
+				"{yellow}10% complete{clear}",
+			err: nil,
+		},
+		"completed": {
+			title:    "cocoon",
+			xblptafn: "completed.json",
+			titleId:  "1610974574",
+			expected: "test's timeline for {cyan}Cocoon{clear}: 5/5 achievements unlocked\n" +
+				"first unlock: 2023-09-20 19:00 UTC (Hatched) | last unlock: 2023-09-29 18:06 UTC (The End)\n" +
+				"5 unlocks over 4 days (1.2/day) | best day: 2023-09-22 (2) | longest streak: 3 days\n" +
+				"{green}completed in 9 days{clear}",
+			err: nil,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			xblthjson := openTestFile(t, "XBLTitleHistory", "has_achievements.json")
+			xblptajson := openTestFile(t, "XBLPlayerTitleAchievements", tc.xblptafn)
+
+			client := req.C()
+			httpmock.ActivateNonDefault(client.GetClient())
+			httpmock.RegisterResponder("GET", "https://xbl.io/api/v2/player/titleHistory/test", func(request *http.Request) (*http.Response, error) {
+				resp := httpmock.NewBytesResponse(http.StatusOK, xblthjson)
+				return resp, nil
+			})
+			httpmock.RegisterResponder("GET", fmt.Sprintf("https://xbl.io/api/v2/achievements/player/test/%s", tc.titleId), func(request *http.Request) (*http.Response, error) {
+				resp := httpmock.NewBytesResponse(http.StatusOK, xblptajson)
+				return resp, nil
+			})
+
+			out, err := xblTimeline(client, testReply(), "test", "test", tc.title)
 			assert.Equal(t, tc.expected, out)
 			assert.ErrorIs(t, tc.err, err)
 		})